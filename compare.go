@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tehbilly/coverage-table/coveragetable"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// compareBaseline collects coverage the same way as for root, but in a temporary git worktree with ref checked out,
+// and returns it as a baseline for the report of root. The worktree is removed again before returning, whatever the
+// outcome.
+func compareBaseline(root, ref string, pkgs packagePattern) (b *coveragetable.Baseline, err error) {
+	top, err := git(root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	// The path may be a directory somewhere inside of the repository, which is where it'll be in the worktree as well.
+	// Git resolves symlinks in the top level, so the path has to be resolved too for the two to line up.
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(top, resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(*tmpDir, "coverage-table-compare-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory for worktree: %w", err)
+	}
+	defer func() {
+		if rerr := os.RemoveAll(dir); rerr != nil && err == nil {
+			err = fmt.Errorf("removing temporary directory for worktree: %w", rerr)
+		}
+	}()
+
+	if _, err := git(root, "worktree", "add", "--detach", dir, ref); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if _, rerr := git(root, "worktree", "remove", "--force", dir); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	wtRoot := filepath.Join(dir, rel)
+	infof("collecting coverage of %s in a temporary worktree", ref)
+	found, repo, filter, err := findFiles(wtRoot, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	// Profiles given on the command line belong to the current tree, so the tests always run for ref
+	r, err := collect(wtRoot, repo, found, filter, nil, pkgs, "")
+	if err != nil {
+		return nil, err
+	}
+	if r, err = group(wtRoot, r); err != nil {
+		return nil, err
+	}
+
+	return coveragetable.NewBaseline(r), nil
+}
+
+// git runs git with args in dir, returning its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running 'git %s': %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFile is the name of the optional file in the target directory holding per-repository defaults for flags
+const configFile = ".coverage-table.yml"
+
+// config holds the flags that can be set in the config file, using the same names as the flags themselves
+type config struct {
+	Include            []string `yaml:"include"`
+	Exclude            []string `yaml:"exclude"`
+	ExcludeTestHelpers *bool    `yaml:"exclude-test-helpers"`
+	TestHelper         []string `yaml:"test-helper"`
+	Threshold          *float64 `yaml:"threshold"`
+	FileThreshold      *float64 `yaml:"file-threshold"`
+	Color              string   `yaml:"color"`
+	ColorThresholds    string   `yaml:"color-thresholds"`
+	Format             string   `yaml:"format"`
+}
+
+// loadConfig reads the config file in dir, returning an empty config if there is none
+func loadConfig(dir string) (config, error) {
+	var c config
+
+	data, err := os.ReadFile(filepath.Join(dir, configFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+
+	// Strict, so a misspelled key doesn't go unnoticed
+	err = yaml.UnmarshalStrict(data, &c)
+	return c, err
+}
+
+// apply sets every flag from the config that wasn't given on the command line, so flags always take precedence over
+// the file. Values go through the flags themselves, which means they're checked just like values from the command line.
+func (c config) apply() error {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	values := map[string][]string{
+		"include":     c.Include,
+		"exclude":     c.Exclude,
+		"test-helper": c.TestHelper,
+	}
+	if c.ExcludeTestHelpers != nil {
+		values["exclude-test-helpers"] = []string{strconv.FormatBool(*c.ExcludeTestHelpers)}
+	}
+	if c.Threshold != nil {
+		values["threshold"] = []string{strconv.FormatFloat(*c.Threshold, 'f', -1, 64)}
+	}
+	if c.FileThreshold != nil {
+		values["file-threshold"] = []string{strconv.FormatFloat(*c.FileThreshold, 'f', -1, 64)}
+	}
+	if c.Color != "" {
+		values["color"] = []string{c.Color}
+	}
+	if c.ColorThresholds != "" {
+		values["color-thresholds"] = []string{c.ColorThresholds}
+	}
+	if c.Format != "" {
+		values["format"] = []string{c.Format}
+	}
+
+	for name, list := range values {
+		if given[name] {
+			continue
+		}
+		for _, value := range list {
+			if err := flag.Set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readPatterns reads the glob patterns in the named file, one on every line. Blank lines and lines starting with '#'
+// are skipped, like in a .gitignore.
+func readPatterns(name string) ([]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPatterns(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "excludes")
+	content := "# Generated code\n*.pb.go\n\n  **/mocks/**  \n\t# indented comment\ninternal/legacy/*.go\r\n\n"
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, err := readPatterns(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"*.pb.go", "**/mocks/**", "internal/legacy/*.go"}
+	if !reflect.DeepEqual(patterns, want) {
+		t.Errorf("readPatterns() = %q, want %q", patterns, want)
+	}
+}
+
+func TestReadPatternsMissingFile(t *testing.T) {
+	if _, err := readPatterns(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("readPatterns() of a missing file returned no error")
+	}
+}
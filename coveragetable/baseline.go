@@ -0,0 +1,115 @@
+package coveragetable
+
+import (
+	"encoding/json"
+	"github.com/olekukonko/tablewriter"
+	"os"
+	"sort"
+)
+
+// Baseline is the coverage from a previous run, read from a report written with -format=json
+type Baseline struct {
+	Files map[string]float64
+	Total float64
+}
+
+// LoadBaseline reads the baseline from the named file
+func LoadBaseline(name string) (*Baseline, error) {
+	bytes, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous Report
+	if err := json.Unmarshal(bytes, &previous); err != nil {
+		return nil, err
+	}
+
+	b := &Baseline{Files: make(map[string]float64), Total: previous.Total}
+	for _, f := range previous.Files {
+		b.Files[f.Name] = f.Coverage
+	}
+
+	return b, nil
+}
+
+// NewBaseline returns the coverage of every row of r as a baseline, for comparing against a report that wasn't written
+// to a file first
+func NewBaseline(r Table) *Baseline {
+	b := &Baseline{Files: make(map[string]float64, len(r.Rows)), Total: r.Total.Percent()}
+	for _, row := range r.Rows {
+		b.Files[row.Name] = row.Percent()
+	}
+
+	return b
+}
+
+// previous returns the coverage of the named row in the baseline, or a dash when it's new
+func (b *Baseline) previous(name string, opts Options) string {
+	if name == "Total" {
+		return opts.Percent(b.Total)
+	}
+	if cov, ok := b.Files[name]; ok {
+		return opts.Percent(cov)
+	}
+
+	return "-"
+}
+
+// delta returns the change in coverage of the named row since the baseline, colored green when coverage went up and
+// red when it went down. The Total row is compared against the baseline total.
+func (b *Baseline) delta(name string, cov float64, opts Options) (string, tablewriter.Colors) {
+	previous, ok := b.Files[name]
+	if name == "Total" {
+		previous, ok = b.Total, true
+	}
+	if !ok {
+		return "new", tablewriter.Colors{}
+	}
+
+	delta := cov - previous
+	switch {
+	case delta > 0:
+		return "+" + opts.Percent(delta), tablewriter.Colors{tablewriter.FgGreenColor}
+	case delta < 0:
+		return opts.Percent(delta), tablewriter.Colors{tablewriter.FgRedColor}
+	default:
+		return opts.Percent(delta), tablewriter.Colors{}
+	}
+}
+
+// removedLines returns table lines for the files in the baseline that are no longer in the report, sorted by name
+func (b *Baseline) removedLines(r Table, opts Options) []tableLine {
+	current := make(map[string]bool, len(r.Rows))
+	for _, row := range r.Rows {
+		current[row.Name] = true
+	}
+
+	var names []string
+	for name := range b.Files {
+		if !current[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := make([]tableLine, 0, len(names))
+	for _, name := range names {
+		var line tableLine
+		line.add(name, tablewriter.Colors{})
+		if opts.Verbose {
+			line.add("-", tablewriter.Colors{})
+			if opts.Partial {
+				line.add("-", tablewriter.Colors{})
+			}
+		}
+		line.add("-", tablewriter.Colors{})
+		if opts.ShowBaseline {
+			line.add(opts.Percent(b.Files[name]), tablewriter.Colors{})
+		}
+		line.add("removed", tablewriter.Colors{})
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,142 @@
+package coveragetable
+
+import (
+	"bytes"
+	"github.com/olekukonko/tablewriter"
+	"golang.org/x/tools/cover"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// baselineTable builds a table of the files in module example.com/m from their profiles
+func baselineTable(t *testing.T, profiles ...*cover.Profile) Table {
+	t.Helper()
+
+	found := GoFiles{Files: make(map[string]Coverage)}
+	for _, p := range profiles {
+		found.Files[strings.TrimPrefix(p.FileName, "example.com/m/")] = Coverage{}
+	}
+	r, err := BuildTable(NewRepository("/m", "example.com/m"), found, profiles, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// savedBaseline writes r with the json format, the way -format=json -output does, and loads it back as a baseline
+func savedBaseline(t *testing.T, r Table) *Baseline {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, Options{Format: "json", Precision: DefaultPrecision}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := LoadBaseline(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestLoadBaseline(t *testing.T) {
+	before := baselineTable(t,
+		profile("example.com/m/a.go", 1, 1, 0),
+		profile("example.com/m/b.go", 1, 1, 1, 1, 0),
+	)
+
+	got := savedBaseline(t, before)
+	want := &Baseline{Files: map[string]float64{"a.go": 50, "b.go": 75}, Total: 4 * 100 / 6.0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadBaseline() = %+v, want %+v", got, want)
+	}
+	if fresh := NewBaseline(before); !reflect.DeepEqual(fresh, got) {
+		t.Errorf("NewBaseline() = %+v, but the saved baseline is %+v", fresh, got)
+	}
+}
+
+func TestLoadBaselineErrors(t *testing.T) {
+	if _, err := LoadBaseline(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("LoadBaseline() of a missing file returned no error")
+	}
+
+	name := filepath.Join(t.TempDir(), "table.txt")
+	if err := os.WriteFile(name, []byte("| FILE | COVERAGE (%) |\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBaseline(name); err == nil {
+		t.Errorf("LoadBaseline() of a table rather than json returned no error")
+	}
+}
+
+func TestBaselineDelta(t *testing.T) {
+	b := &Baseline{Files: map[string]float64{"up.go": 50, "down.go": 80, "same.go": 60}, Total: 70}
+	opts := Options{Precision: DefaultPrecision}
+
+	tests := []struct {
+		name  string
+		cov   float64
+		want  string
+		color tablewriter.Colors
+	}{
+		{name: "up.go", cov: 75, want: "+25.00", color: tablewriter.Colors{tablewriter.FgGreenColor}},
+		{name: "down.go", cov: 60, want: "-20.00", color: tablewriter.Colors{tablewriter.FgRedColor}},
+		{name: "same.go", cov: 60, want: "0.00", color: tablewriter.Colors{}},
+		{name: "new.go", cov: 10, want: "new", color: tablewriter.Colors{}},
+		{name: "Total", cov: 72.5, want: "+2.50", color: tablewriter.Colors{tablewriter.FgGreenColor}},
+	}
+
+	for _, tt := range tests {
+		got, color := b.delta(tt.name, tt.cov, opts)
+		if got != tt.want || !reflect.DeepEqual(color, tt.color) {
+			t.Errorf("delta(%s) = %s %v, want %s %v", tt.name, got, color, tt.want, tt.color)
+		}
+	}
+}
+
+func TestRenderBaseline(t *testing.T) {
+	before := baselineTable(t,
+		profile("example.com/m/a.go", 1, 1, 0),
+		profile("example.com/m/b.go", 1, 1, 1, 1, 0),
+		profile("example.com/m/gone.go", 1, 1),
+	)
+	after := baselineTable(t,
+		profile("example.com/m/a.go", 1, 1, 1),
+		profile("example.com/m/b.go", 1, 1, 0, 0, 0),
+		profile("example.com/m/new.go", 1, 0),
+	)
+	after.Baseline = savedBaseline(t, before)
+
+	var buf bytes.Buffer
+	if err := after.Render(&buf, Options{Precision: DefaultPrecision, ShowBaseline: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every row is the name, the coverage now, the coverage then, and the change
+	want := [][]string{
+		{"a.go", "100.00", "50.00", "+50.00"},
+		{"b.go", "25.00", "75.00", "-50.00"},
+		{"new.go", "0.00", "-", "new"},
+		{"gone.go", "-", "100.00", "removed"},
+		{"TOTAL", "42.86", "71.43", "-28.57"},
+	}
+	var got [][]string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "|") || strings.Contains(line, "FILE") {
+			continue
+		}
+		got = append(got, strings.Fields(strings.ReplaceAll(line, "|", " ")))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q\n%s", got, want, buf.String())
+	}
+}
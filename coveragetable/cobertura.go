@@ -0,0 +1,117 @@
+package coveragetable
+
+import (
+	"encoding/xml"
+	"io"
+	"path"
+	"time"
+)
+
+// coberturaDocType is the DTD Cobertura reports are validated against
+const coberturaDocType = `<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        float64            `xml:"line-rate,attr"`
+	BranchRate      float64            `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      float64            `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Complexity float64          `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	Filename   string          `xml:"filename,attr"`
+	LineRate   float64         `xml:"line-rate,attr"`
+	BranchRate float64         `xml:"branch-rate,attr"`
+	Complexity float64         `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// lineRate returns the fraction of lines that ran, which Cobertura expects between 0 and 1
+func lineRate(hit, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+
+	return float64(hit) / float64(valid)
+}
+
+// printCobertura renders the lines of every file as a Cobertura XML report, with a package per directory and a class
+// per file. Go has no branch coverage, so branch rates are always 0.
+func printCobertura(w io.Writer, r Table, opts Options) error {
+	doc := coberturaCoverage{
+		// Names in the report are relative to the directory coverage-table was run in
+		Sources: []string{"."},
+	}
+	if !opts.Stable {
+		doc.Timestamp = time.Now().Unix()
+	}
+
+	// Files of the same package aren't always next to each other, as 'pkg/a/a.go' sorts before 'pkg/b.go'
+	pkgs := make(map[string]int)
+	var pkgHit, pkgValid []int
+	for _, row := range r.Rows {
+		counts := lineCounts(r.blocks[row.Name])
+		hit, valid := counts.hit(), len(counts)
+
+		class := coberturaClass{
+			Name:     path.Base(row.Name),
+			Filename: row.Name,
+			LineRate: lineRate(hit, valid),
+		}
+		for _, line := range counts.lines() {
+			class.Lines = append(class.Lines, coberturaLine{Number: line, Hits: counts[line]})
+		}
+
+		dir := path.Dir(row.Name)
+		i, ok := pkgs[dir]
+		if !ok {
+			i = len(doc.Packages)
+			pkgs[dir] = i
+			doc.Packages = append(doc.Packages, coberturaPackage{Name: dir})
+			pkgHit, pkgValid = append(pkgHit, 0), append(pkgValid, 0)
+		}
+		pkgHit[i] += hit
+		pkgValid[i] += valid
+		doc.Packages[i].Classes = append(doc.Packages[i].Classes, class)
+		doc.Packages[i].LineRate = lineRate(pkgHit[i], pkgValid[i])
+
+		doc.LinesCovered += hit
+		doc.LinesValid += valid
+	}
+	doc.LineRate = lineRate(doc.LinesCovered, doc.LinesValid)
+
+	if _, err := io.WriteString(w, xml.Header+coberturaDocType+"\n"); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,126 @@
+package coveragetable
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeader matches the line ranges of a unified diff hunk header, leaving out the count when it's 1
+var hunkHeader = regexp.MustCompile(`^@@ -[0-9]+(?:,([0-9]+))? \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// ChangedLines runs 'git diff' against ref in dir, returning the lines that were added or changed in each file. File
+// names are relative to dir, like the names in a report.
+func ChangedLines(dir, ref string) (map[string]map[int]bool, error) {
+	cmd := exec.Command("git", "diff", "--relative", "--unified=0", "--no-color", ref, "--", ".")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running 'git diff %s': %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseDiff(bytes.NewReader(out))
+}
+
+// parseDiff reads a diff without context lines, returning the lines of every file that were added or changed, by their
+// line number after the change. Deleted files have nothing left to cover, so they aren't part of it.
+func parseDiff(r io.Reader) (map[string]map[int]bool, error) {
+	changed := make(map[string]map[int]bool)
+	var lines map[int]bool
+	// pending is the number of lines of the current hunk still to come, which can look like file headers themselves
+	pending := 0
+
+	s := bufio.NewScanner(r)
+	// Minified or generated files can have very long lines
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+
+		if pending > 0 && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) {
+			pending--
+			continue
+		}
+
+		if strings.HasPrefix(line, "+++ ") {
+			lines = nil
+			if name := strings.TrimPrefix(line, "+++ "); strings.HasPrefix(name, "b/") {
+				lines = make(map[int]bool)
+				changed[strings.TrimPrefix(name, "b/")] = lines
+			}
+			continue
+		}
+
+		m := hunkHeader.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		count := func(s string) int {
+			if s == "" {
+				return 1
+			}
+			n, _ := strconv.Atoi(s)
+			return n
+		}
+		start, _ := strconv.Atoi(m[2])
+		removed, added := count(m[1]), count(m[3])
+		pending = removed + added
+		// The hunks of a deleted file only remove lines
+		if lines == nil {
+			continue
+		}
+		for i := start; i < start+added; i++ {
+			lines[i] = true
+		}
+	}
+
+	return changed, s.Err()
+}
+
+// DiffTable narrows a report down to the statements on changed lines, leaving out files without any
+func DiffTable(r Table, changed map[string]map[int]bool) Table {
+	diffed := Table{blocks: r.blocks, weakLimit: r.weakLimit}
+
+	for _, fileRow := range r.Rows {
+		lines := changed[fileRow.Name]
+		if len(lines) == 0 {
+			continue
+		}
+
+		var c Coverage
+		for _, block := range r.blocks[fileRow.Name] {
+			if !blockChanged(lines, block.StartLine, block.EndLine) {
+				continue
+			}
+
+			c.addBlock(block, r.weakLimit)
+		}
+
+		if c.Total == 0 {
+			continue
+		}
+
+		diffed.Rows = append(diffed.Rows, Row{Name: fileRow.Name, Coverage: c})
+		diffed.Total.Add(c)
+	}
+
+	return diffed
+}
+
+// blockChanged reports whether any of the lines from start to end changed
+func blockChanged(lines map[int]bool, start, end int) bool {
+	for i := start; i <= end; i++ {
+		if lines[i] {
+			return true
+		}
+	}
+
+	return false
+}
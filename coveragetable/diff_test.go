@@ -0,0 +1,86 @@
+package coveragetable
+
+import (
+	"golang.org/x/tools/cover"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	f, err := os.Open("testdata/changes.diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := parseDiff(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[int]bool{
+		// A changed line and two added ones
+		"a.go": {4: true, 5: true, 7: true, 8: true},
+		// The markers for the missing newline aren't lines of their own
+		"c.go": {3: true},
+		// A new file where an added line looks like a file header
+		"d.go": {1: true, 2: true, 3: true, 4: true, 5: true},
+		// Renamed files go by their new name
+		"renamed.go": {8: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiff() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDiffRemovedLines(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+index cc682bd..f379bd7 100644
+--- a/a.go
++++ b/a.go
+@@ -4,2 +3,0 @@ func A() int {
+-	x := 1
+-	return x
+diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+`
+	got, err := parseDiff(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lines that only went away leave nothing to cover, and a rename alone changes no lines
+	want := map[string]map[int]bool{"a.go": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiff() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffTable(t *testing.T) {
+	r := baselineTable(t,
+		&cover.Profile{FileName: "example.com/m/a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+			block(3, 5, 2, 1),
+			block(7, 9, 3, 0),
+		}},
+		&cover.Profile{FileName: "example.com/m/b.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1)}},
+		&cover.Profile{FileName: "example.com/m/c.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1)}},
+	)
+	changed := map[string]map[int]bool{
+		"a.go": {4: true, 8: true},
+		// Only a comment above the code changed
+		"b.go": {1: true},
+	}
+
+	diffed := DiffTable(r, changed)
+	want := []Row{{Name: "a.go", Coverage: Coverage{Covered: 2, Total: 5}}}
+	if !reflect.DeepEqual(diffed.Rows, want) {
+		t.Errorf("rows = %+v, want %+v", diffed.Rows, want)
+	}
+	if diffed.Total != (Coverage{Covered: 2, Total: 5}) {
+		t.Errorf("total = %+v, want 2 of 5 statements", diffed.Total)
+	}
+}
@@ -0,0 +1,321 @@
+package coveragetable
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// WalkOptions controls which go files FindGoFiles picks up
+type WalkOptions struct {
+	// IncludeMain picks up files in package main too
+	IncludeMain bool
+	// IgnoreGenerated leaves out files with a "Code generated ... DO NOT EDIT." header
+	IgnoreGenerated bool
+	// ExcludeDirs holds glob patterns of directories that aren't walked into at all
+	ExcludeDirs []string
+	// FollowSymlinks walks into symlinked directories, which filepath.Walk doesn't do on its own
+	FollowSymlinks bool
+	// RespectGitignore skips files and directories ignored by .gitignore files, in the path itself and the
+	// directories above it up to the top of the git repository
+	RespectGitignore bool
+	// Build decides which files are built, and so which files can be covered
+	Build *build.Context
+}
+
+// GoFiles holds the go files found by FindGoFiles
+type GoFiles struct {
+	// Files maps every file that can be covered to its coverage, which is filled in from the profiles later
+	Files map[string]Coverage
+	// Skipped holds files that were deliberately left out, but could still show up in a coverage profile
+	Skipped map[string]bool
+	// Empty holds files without any statements to cover, like those only declaring constants
+	Empty map[string]bool
+}
+
+// FindGoFiles walks path for go files that can be covered by tests
+func FindGoFiles(path string, opts WalkOptions) (GoFiles, error) {
+	found := GoFiles{
+		Files:   make(map[string]Coverage),
+		Skipped: make(map[string]bool),
+		Empty:   make(map[string]bool),
+	}
+
+	ap, err := filepath.Abs(path)
+	if err != nil {
+		warnf("unable to get absolute path for %s: %s", path, err)
+		ap = path
+	}
+
+	// A single path that can't be read shouldn't keep the rest of the tree from being reported on
+	unreadable := 0
+	skip := func(p string, fi os.FileInfo, err error) error {
+		warnf("skipping %s: %s", p, err)
+		unreadable++
+		if fi != nil && fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	// visited holds the real path of every directory walked so far when following symlinks. A symlink pointing back up
+	// the tree would otherwise be walked over and over again, so directories that were already walked are skipped.
+	visited := make(map[string]bool)
+
+	var ignore gitignore
+	if opts.RespectGitignore {
+		if err := ignore.loadAncestors(ap); err != nil {
+			return found, err
+		}
+	}
+
+	var walk filepath.WalkFunc
+	walk = func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Without the path itself there's nothing to walk at all
+			if p == ap {
+				return err
+			}
+			return skip(p, fi, err)
+		}
+
+		// Skip hidden directories/files
+		if fi.IsDir() && strings.HasPrefix(fi.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		// Skip 'testdata' directories, as the go tool will ignore those as well
+		if fi.IsDir() && fi.Name() == "testdata" {
+			return filepath.SkipDir
+		}
+
+		if opts.RespectGitignore {
+			if p != ap && ignore.ignored(p, fi.IsDir()) {
+				debugf("skipping %s: ignored by .gitignore", p)
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fi.IsDir() {
+				if err := ignore.load(p); err != nil {
+					return skip(p, fi, err)
+				}
+			}
+		}
+
+		// Skip directories excluded by name or path, which is a lot cheaper than walking them and filtering their files
+		if fi.IsDir() && p != ap && len(opts.ExcludeDirs) > 0 {
+			rel, err := filepath.Rel(ap, p)
+			if err != nil {
+				return err
+			}
+			if matchAny(opts.ExcludeDirs, filepath.ToSlash(rel)) {
+				debugf("skipping directory %s: excluded by -exclude-dir", filepath.ToSlash(rel))
+				return filepath.SkipDir
+			}
+		}
+
+		if opts.FollowSymlinks && (fi.IsDir() || fi.Mode()&os.ModeSymlink != 0) {
+			real, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return skip(p, fi, err)
+			}
+			target, err := os.Stat(real)
+			if err != nil {
+				return skip(p, fi, err)
+			}
+
+			switch {
+			case !target.IsDir():
+			case visited[real] && fi.IsDir():
+				debugf("skipping directory %s: already walked %s", p, real)
+				return filepath.SkipDir
+			case visited[real]:
+				return nil
+			case fi.IsDir():
+				visited[real] = true
+			default:
+				// filepath.Walk doesn't descend into the symlink itself, so the directory it points to is walked
+				// separately, with paths kept below the symlink as if it were a regular directory
+				return filepath.Walk(real, func(rp string, fi os.FileInfo, err error) error {
+					rel, rerr := filepath.Rel(real, rp)
+					if rerr != nil {
+						return rerr
+					}
+					return walk(filepath.Join(p, rel), fi, err)
+				})
+			}
+		}
+
+		// Ignore anything but go files, and test files
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".go") || strings.HasSuffix(fi.Name(), "_test.go") {
+			return nil
+		}
+
+		if err := found.add(ap, p, opts); err != nil {
+			return skip(p, fi, err)
+		}
+
+		return nil
+	}
+
+	if err := filepath.Walk(ap, walk); err != nil {
+		return GoFiles{}, err
+	}
+
+	if unreadable > 0 {
+		warnf("paths skipped because they could not be read: %d", unreadable)
+	}
+
+	return found, nil
+}
+
+// add adds the go file at p to found, unless it's left out by opts or has nothing to cover. Names are relative to
+// root.
+func (found GoFiles) add(root, p string, opts WalkOptions) error {
+	// Clean up path to match the slash-separated names from coverage profiles
+	fp, err := filepath.Rel(root, p)
+	if err != nil {
+		return err
+	}
+	fp = filepath.ToSlash(fp)
+
+	// Ignore files that aren't built with the current build constraints, as they won't be tested either
+	if ok, err := opts.Build.MatchFile(filepath.Dir(p), filepath.Base(p)); err != nil {
+		return err
+	} else if !ok {
+		debugf("skipping %s: excluded by build constraints", p)
+		return nil
+	}
+
+	// Everything below works off the one parse, comments included for the generated code header
+	file, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	// MatchFile leaves cgo to go/build's Import, which doesn't build files importing "C" without cgo. A profile from
+	// somewhere cgo is enabled still covers them, which is no reason to warn about them.
+	if !opts.Build.CgoEnabled && importsC(file) {
+		debugf("skipping %s: uses cgo, which is disabled", fp)
+		found.Skipped[fp] = true
+		return nil
+	}
+
+	// External test packages only ever hold tests, so they're never covered, whatever the file is called
+	if strings.HasSuffix(file.Name.Name, "_test") {
+		debugf("skipping %s: external test package", fp)
+		return nil
+	}
+
+	// Skip if this is a file in package main, unless asked for
+	if file.Name.Name == "main" && !opts.IncludeMain {
+		debugf("skipping %s: package main", fp)
+		found.Skipped[fp] = true
+		return nil
+	}
+
+	// Skip generated code, as there's no point in writing tests for it, unless asked for
+	if opts.IgnoreGenerated && isGenerated(file) {
+		debugf("skipping %s: generated", fp)
+		found.Skipped[fp] = true
+		return nil
+	}
+
+	// Skip go files that only contain interfaces, as they have nothing to cover
+	if onlyInterfaces(file) {
+		debugf("skipping %s: only declares interfaces", fp)
+		return nil
+	}
+
+	found.Files[fp] = Coverage{}
+	if isEmpty(file) {
+		found.Empty[fp] = true
+	}
+
+	return nil
+}
+
+// generatedHeader matches the comment marking a go file as generated, see https://golang.org/s/generatedcode
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// importsC reports whether file uses cgo
+func importsC(file *ast.File) bool {
+	for _, spec := range file.Imports {
+		if spec.Path.Value == `"C"` {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isGenerated reports whether file has a generated code header, a line comment before its package clause
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			// The raw text of the comment, so a block comment never matches
+			if generatedHeader.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isEmpty reports whether file has no statements that could be covered at all
+func isEmpty(file *ast.File) bool {
+	// Statements only live in function bodies, including those of function literals in variable declarations
+	empty := true
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil && len(n.Body.List) > 0 {
+				empty = false
+			}
+		case *ast.FuncLit:
+			if len(n.Body.List) > 0 {
+				empty = false
+			}
+		}
+		return empty
+	})
+
+	return empty
+}
+
+// onlyInterfaces reports whether file declares at least one interface type, and nothing else apart from its imports
+func onlyInterfaces(file *ast.File) bool {
+	interfaces := 0
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			return false
+		}
+
+		switch gen.Tok {
+		case token.IMPORT:
+		case token.TYPE:
+			for _, spec := range gen.Specs {
+				if _, ok := spec.(*ast.TypeSpec).Type.(*ast.InterfaceType); !ok {
+					return false
+				}
+				interfaces++
+			}
+		default:
+			return false
+		}
+	}
+
+	return interfaces > 0
+}
@@ -0,0 +1,264 @@
+package coveragetable
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"golang.org/x/tools/cover"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes every file in files, by slash-separated name, to a new temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// walk finds the go files in dir with opts, building them for the default context unless opts says otherwise
+func walk(t *testing.T, dir string, opts WalkOptions) GoFiles {
+	t.Helper()
+
+	if opts.Build == nil {
+		ctx := build.Default
+		opts.Build = &ctx
+	}
+	found, err := FindGoFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return found
+}
+
+func TestOnlyInterfaces(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{
+			name: "interfaces",
+			src: `package store
+
+import "context"
+
+// Store keeps values
+type Store interface {
+	Get(ctx context.Context, key string) (string, error)
+}
+
+type (
+	Closer interface{ Close() error }
+	Flusher interface{ Flush() }
+)
+`,
+			want: true,
+		},
+		{
+			name: "interface and implementation",
+			src: `package store
+
+type Store interface {
+	Get(key string) string
+}
+
+type memory map[string]string
+
+func (m memory) Get(key string) string {
+	return m[key]
+}
+`,
+			want: false,
+		},
+		{
+			name: "interface and variable",
+			src: `package store
+
+type Store interface {
+	Get(key string) string
+}
+
+var _ Store = nil
+`,
+			want: false,
+		},
+		{
+			name: "nothing",
+			src:  "package store\n",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.ParseFile(token.NewFileSet(), "store.go", tt.src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := onlyInterfaces(file); got != tt.want {
+				t.Errorf("onlyInterfaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindGoFilesSkipsInterfaceOnlyFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"store.go":  "package store\n\ntype Store interface {\n\tGet(key string) string\n}\n",
+		"memory.go": "package store\n\ntype Getter interface {\n\tGet(key string) string\n}\n\ntype memory map[string]string\n\nfunc (m memory) Get(key string) string {\n\treturn m[key]\n}\n",
+	})
+
+	found := walk(t, dir, WalkOptions{})
+	if _, ok := found.Files["store.go"]; ok {
+		t.Errorf("store.go only declares an interface, but was found")
+	}
+	if _, ok := found.Files["memory.go"]; !ok {
+		t.Errorf("memory.go has code next to its interface, but wasn't found")
+	}
+}
+
+func TestFindGoFilesSkipsPackageMain(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cmd/tool/main.go": "package main // the tool itself\n\nfunc main() {\n\trun()\n}\n",
+		"cmd/tool/run.go":  "package main\n\nfunc run() {\n\tprintln(\"running\")\n}\n",
+		"clause.go":        "package clause\n\n// Main is what every command starts with\nconst Main = \"package main\"\n\nfunc Clause() string {\n\treturn Main\n}\n",
+	})
+
+	found := walk(t, dir, WalkOptions{})
+	for _, name := range []string{"cmd/tool/main.go", "cmd/tool/run.go"} {
+		if _, ok := found.Files[name]; ok {
+			t.Errorf("%s is in package main, but was found", name)
+		}
+		if !found.Skipped[name] {
+			t.Errorf("%s is in package main, but wasn't marked as skipped", name)
+		}
+	}
+	if _, ok := found.Files["clause.go"]; !ok {
+		t.Errorf("clause.go only has package main in a string, but wasn't found")
+	}
+
+	found = walk(t, dir, WalkOptions{IncludeMain: true})
+	if _, ok := found.Files["cmd/tool/main.go"]; !ok {
+		t.Errorf("cmd/tool/main.go wasn't found with IncludeMain")
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	const body = "package gen\n\nfunc Value() int {\n\treturn 1\n}\n"
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{name: "header", src: "// Code generated by protoc-gen-go. DO NOT EDIT.\n\n" + body, want: true},
+		{name: "after other comments", src: "// Copyright The Authors.\n\n// Code generated by stringer; DO NOT EDIT.\n" + body, want: true},
+		{name: "no header", src: body, want: false},
+		{name: "no period", src: "// Code generated by mockgen. DO NOT EDIT\n" + body, want: false},
+		{name: "lowercase", src: "// code generated by hand. DO NOT EDIT.\n" + body, want: false},
+		{name: "block comment", src: "/* Code generated by protoc-gen-go. DO NOT EDIT. */\n" + body, want: false},
+		{name: "inside a block comment", src: "/*\n// Code generated by protoc-gen-go. DO NOT EDIT.\n*/\n" + body, want: false},
+		{name: "windows line endings", src: "// Code generated by protoc-gen-go. DO NOT EDIT.\r\n\r\n" + body, want: true},
+		{name: "after the package clause", src: body + "\n// Code generated by protoc-gen-go. DO NOT EDIT.\n", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"gen.go": tt.src})
+
+			file, err := parser.ParseFile(token.NewFileSet(), "gen.go", tt.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isGenerated(file); got != tt.want {
+				t.Errorf("isGenerated() = %v, want %v", got, tt.want)
+			}
+
+			found := walk(t, dir, WalkOptions{IgnoreGenerated: true})
+			if _, ok := found.Files["gen.go"]; ok == tt.want {
+				t.Errorf("found gen.go = %v with IgnoreGenerated, want %v", ok, !tt.want)
+			}
+		})
+	}
+}
+
+// logged collects the warnings logged for the rest of the test
+func logged(t *testing.T) *[]string {
+	var warnings []string
+	old := Logf
+	Logf = func(level Level, format string, args ...interface{}) {
+		if level >= LevelWarn {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		}
+	}
+	t.Cleanup(func() { Logf = old })
+
+	return &warnings
+}
+
+func TestExternalTestPackage(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod":              "module example.com/m\n\ngo 1.18\n",
+		"store/store.go":      "package store\n\nfunc Key(prefix, name string) string {\n\treturn prefix + \"/\" + name\n}\n",
+		"store/store_test.go": "package store_test\n\nimport (\n\t\"example.com/m/store\"\n\t\"testing\"\n)\n\nfunc TestKey(t *testing.T) {\n\tif store.Key(\"a\", \"b\") != \"a/b\" {\n\t\tt.Fail()\n\t}\n}\n",
+	})
+	warnings := logged(t)
+
+	found := walk(t, dir, WalkOptions{})
+	profiles := []*cover.Profile{profile("example.com/m/store/store.go", 1, 1)}
+	r, err := BuildTable(NewRepository(dir, "example.com/m"), found, profiles, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Rows) != 1 || r.Rows[0].Name != "store/store.go" || r.Rows[0].Percent() != 100 {
+		t.Errorf("rows = %+v, want store/store.go at 100%%", r.Rows)
+	}
+	if len(*warnings) > 0 {
+		t.Errorf("warned about %q", *warnings)
+	}
+}
+
+func TestCgoDisabled(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"sum/sum.go": "package sum\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n",
+		"sum/cgo.go": "package sum\n\n// int add(int a, int b) { return a + b; }\nimport \"C\"\n\nfunc Add(a, b int) int {\n\treturn int(C.add(C.int(a), C.int(b)))\n}\n",
+	})
+	warnings := logged(t)
+
+	ctx := build.Default
+	ctx.CgoEnabled = false
+	found := walk(t, dir, WalkOptions{Build: &ctx})
+	if _, ok := found.Files["sum/cgo.go"]; ok || !found.Skipped["sum/cgo.go"] {
+		t.Errorf("sum/cgo.go uses cgo, but wasn't skipped with cgo disabled")
+	}
+
+	// A profile from a run with cgo enabled still covers the file
+	profiles := []*cover.Profile{profile("example.com/m/sum/sum.go", 1, 1), profile("example.com/m/sum/cgo.go", 1, 1)}
+	if _, err := BuildTable(NewRepository(dir, "example.com/m"), found, profiles, FileFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*warnings) > 0 {
+		t.Errorf("warned about %q", *warnings)
+	}
+
+	ctx.CgoEnabled = true
+	found = walk(t, dir, WalkOptions{Build: &ctx})
+	if _, ok := found.Files["sum/cgo.go"]; !ok {
+		t.Errorf("sum/cgo.go wasn't found with cgo enabled")
+	}
+}
@@ -0,0 +1,507 @@
+package coveragetable
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options controls how a report is rendered. The zero value renders a plain table.
+type Options struct {
+	// Format is one of the names returned by FormatNames, or "table" when empty
+	Format string
+	// Verbose adds the number of covered and total statements to the table
+	Verbose bool
+	// Partial adds the number of weakly covered statements to the table next to the other statement counts, see
+	// CountWeak
+	Partial bool
+	// Summary only renders the total coverage
+	Summary bool
+	// NoFooter leaves out the Total row of the table, csv, and markdown formats
+	NoFooter bool
+	// NoHeader leaves out the row naming the columns of the table
+	NoHeader bool
+	// Color renders the table with color
+	Color bool
+	// ColorThresholds are the percentages at which the color changes, see ParseColorThresholds. The zero value stands
+	// for DefaultColorThresholds.
+	ColorThresholds [4]float64
+	// Precision is the number of decimals percentages are shown with, usually DefaultPrecision. Colors and thresholds
+	// always use the exact percentage.
+	Precision int
+	// ShowBaseline adds the coverage in the baseline next to the change since then, when the report has a baseline
+	ShowBaseline bool
+	// Grade adds a letter grade for the total coverage below the table, and to the json format
+	Grade bool
+	// FileThreshold is the coverage below which the sarif format reports a file
+	FileThreshold float64
+	// Stable leaves out anything that changes from one run to the next, like the timestamp of the cobertura format, so
+	// the same coverage always renders to the same bytes. Rows are always in a deterministic order either way.
+	Stable bool
+	// NoColorTotal renders the Total row of the table without color, even when Color is set
+	NoColorTotal bool
+	// Name heads the column of row names in the table and markdown formats, like "Package" for rows grouped by
+	// package. It's "File" when empty.
+	Name string
+}
+
+// formats maps the names accepted by -format to the function rendering the report in that format
+var formats = map[string]func(w io.Writer, r Table, opts Options) error{
+	"table":      printCoverTable,
+	"json":       printJSON,
+	"csv":        printCSV,
+	"markdown":   printMarkdown,
+	"badge-json": printBadgeJSON,
+	"html":       printHTML,
+	"lcov":       printLCOV,
+	"cobertura":  printCobertura,
+	"sarif":      printSARIF,
+}
+
+// FormatNames returns the names of every format a report can be rendered in, sorted
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// IsFormat reports whether a report can be rendered in the named format
+func IsFormat(name string) bool {
+	_, ok := formats[name]
+	return ok
+}
+
+// Render renders the report to w in the format set by opts
+func (r Table) Render(w io.Writer, opts Options) error {
+	name := opts.Format
+	if name == "" {
+		name = "table"
+	}
+
+	render, ok := formats[name]
+	if !ok {
+		return fmt.Errorf("unknown format %q", name)
+	}
+
+	return render(w, r, opts)
+}
+
+func printCoverTable(w io.Writer, r Table, opts Options) error {
+	// The one number is all that's wanted, so there's no need for a table around it
+	if opts.Summary {
+		_, err := fmt.Fprintln(w, opts.Percent(r.Total.Percent()))
+		return err
+	}
+
+	if r.Header != nil {
+		if _, err := fmt.Fprintln(w, r.Header); err != nil {
+			return err
+		}
+	}
+
+	table := tablewriter.NewWriter(w)
+	// Rows are one line each, however long the name: function rows have spaces that tablewriter would wrap at
+	table.SetAutoWrapText(false)
+	align := []int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT}
+	if opts.Verbose {
+		align = append(align, tablewriter.ALIGN_RIGHT)
+		if opts.Partial {
+			align = append(align, tablewriter.ALIGN_RIGHT)
+		}
+	}
+	if r.Baseline != nil {
+		align = append(align, tablewriter.ALIGN_RIGHT)
+		if opts.ShowBaseline {
+			align = append(align, tablewriter.ALIGN_RIGHT)
+		}
+	}
+	table.SetColumnAlignment(align)
+
+	if !opts.NoHeader {
+		// In the same order as the cells of coverageLine
+		header := []string{opts.name()}
+		if opts.Verbose {
+			header = append(header, "Stmts")
+			if opts.Partial {
+				header = append(header, "Weak")
+			}
+		}
+		header = append(header, "Coverage (%)")
+		if r.Baseline != nil {
+			if opts.ShowBaseline {
+				header = append(header, "Baseline (%)")
+			}
+			header = append(header, "Δ")
+		}
+		table.SetHeader(header)
+
+		if opts.Color {
+			colors := make([]tablewriter.Colors, len(header))
+			for i := range colors {
+				colors[i] = tablewriter.Colors{tablewriter.Bold}
+			}
+			table.SetHeaderColor(colors...)
+		}
+	}
+
+	lines := make([]tableLine, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		lines = append(lines, coverageLine(r, row, opts))
+	}
+	if r.Baseline != nil {
+		lines = append(lines, r.Baseline.removedLines(r, opts)...)
+	}
+
+	for _, line := range lines {
+		if opts.Color {
+			table.Rich(line.cells, line.colors)
+		} else {
+			table.Append(line.cells)
+		}
+	}
+
+	if !opts.NoFooter {
+		footer := coverageLine(r, Row{Name: "Total", Coverage: r.Total}, opts)
+		table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
+		table.SetFooter(footer.cells)
+		if opts.Color && !opts.NoColorTotal {
+			table.SetFooterColor(footer.colors...)
+		}
+	}
+	if r.Hidden > 0 {
+		table.SetCaption(true, hiddenNote(r))
+	}
+	table.Render()
+
+	if opts.Grade {
+		_, err := fmt.Fprintf(w, "Grade: %s\n", opts.grade(r.Total.Percent()))
+		return err
+	}
+
+	return nil
+}
+
+// hiddenNote explains that the rows shown aren't all of them, so the total doesn't look off
+func hiddenNote(r Table) string {
+	if r.Hidden == 1 {
+		return "1 more row in total"
+	}
+	return fmt.Sprintf("%d more rows in total", r.Hidden)
+}
+
+// tableLine holds the cells of a single line in the table, along with their colors
+type tableLine struct {
+	cells  []string
+	colors []tablewriter.Colors
+}
+
+func (l *tableLine) add(cell string, color tablewriter.Colors) {
+	l.cells = append(l.cells, cell)
+	l.colors = append(l.colors, color)
+}
+
+// coverageLine builds the table line for a row of the report, including the statement counts when rendering with
+// Verbose and the change since the baseline when there is one
+func coverageLine(r Table, row Row, opts Options) tableLine {
+	cov := row.Percent()
+	colors := opts.colorsForPercent(cov)
+	// There's nothing to cover in an empty row, so there's nothing to warn about either
+	if row.Empty {
+		colors = []tablewriter.Colors{{}, {}}
+	}
+
+	var line tableLine
+	line.add(row.Name, colors[0])
+	if opts.Verbose {
+		line.add(fmt.Sprintf("%d/%d", row.Covered, row.Total), tablewriter.Colors{})
+		if opts.Partial {
+			line.add(strconv.FormatInt(row.Weak, 10), tablewriter.Colors{})
+		}
+	}
+	line.add(opts.formatPercent(row), colors[1])
+	if r.Baseline != nil {
+		if opts.ShowBaseline {
+			previous := r.Baseline.previous(row.Name, opts)
+			if row.Empty {
+				previous = "-"
+			}
+			line.add(previous, tablewriter.Colors{})
+		}
+		line.add(r.Baseline.delta(row.Name, cov, opts))
+	}
+
+	return line
+}
+
+// DefaultPrecision is the number of decimals percentages are shown with, unless set otherwise
+const DefaultPrecision = 2
+
+// Percent formats a percentage for display with the decimals set by Precision
+func (o Options) Percent(cov float64) string {
+	return strconv.FormatFloat(cov, 'f', o.Precision, 64)
+}
+
+// name returns what the rows are, for the header of the column naming them
+func (o Options) name() string {
+	if o.Name == "" {
+		return "File"
+	}
+	return o.Name
+}
+
+// formatPercent formats the coverage of a row for display, using a dash for rows without any statements
+func (o Options) formatPercent(row Row) string {
+	if row.Empty {
+		return "-"
+	}
+
+	return o.Percent(row.Percent())
+}
+
+// DefaultColorThresholds are the percentages coverage has to reach to go from bright red to red, yellow, green, and
+// finally bright green, unless set otherwise
+var DefaultColorThresholds = [4]float64{40, 60, 80, 90}
+
+// ParseColorThresholds parses a comma separated list of color thresholds, which have to be ascending and between 0
+// and 100
+func ParseColorThresholds(s string) ([4]float64, error) {
+	var thresholds [4]float64
+
+	parts := strings.Split(s, ",")
+	if len(parts) != len(thresholds) {
+		return thresholds, fmt.Errorf("expected %d thresholds, got %d", len(thresholds), len(parts))
+	}
+
+	for i, part := range parts {
+		t, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return thresholds, err
+		}
+		if t < 0 || t > 100 {
+			return thresholds, fmt.Errorf("threshold %v is not between 0 and 100", t)
+		}
+		if i > 0 && t < thresholds[i-1] {
+			return thresholds, fmt.Errorf("thresholds must be ascending, but %v comes after %v", t, thresholds[i-1])
+		}
+		thresholds[i] = t
+	}
+
+	return thresholds, nil
+}
+
+// colorBand returns the band set by the color thresholds that cov falls in, from 0 for bright red up to 4 for bright
+// green. A threshold belongs to the band it starts, so with the defaults exactly 80% is green and anything below it
+// is yellow, even when it's shown rounded up to 80.00.
+func (o Options) colorBand(cov float64) int {
+	thresholds := o.ColorThresholds
+	if thresholds == [4]float64{} {
+		thresholds = DefaultColorThresholds
+	}
+
+	for i, t := range thresholds {
+		if cov < t {
+			return i
+		}
+	}
+
+	return len(thresholds)
+}
+
+// grades are the letter grades for each band returned by colorBand
+var grades = []string{"F", "D", "C", "B", "A"}
+
+// grade returns the letter grade for cov, which uses the same boundaries as the colors
+func (o Options) grade(cov float64) string {
+	return grades[o.colorBand(cov)]
+}
+
+// bandColors are the table colors for each band returned by colorBand
+var bandColors = []tablewriter.Colors{
+	{tablewriter.FgHiRedColor},
+	{tablewriter.FgRedColor},
+	{tablewriter.FgYellowColor},
+	{tablewriter.FgGreenColor},
+	{tablewriter.FgHiGreenColor},
+}
+
+func (o Options) colorsForPercent(cov float64) []tablewriter.Colors {
+	// cov == 0 means that there are no tests covering the file at all
+	if cov == 0 {
+		return []tablewriter.Colors{{tablewriter.FgHiRedColor}, {tablewriter.FgHiRedColor}}
+	}
+
+	return []tablewriter.Colors{{}, bandColors[o.colorBand(cov)]}
+}
+
+// FileCoverage is the coverage of a single row of a report, the way it's written by the json format
+type FileCoverage struct {
+	Name              string  `json:"name"`
+	Coverage          float64 `json:"coverage"`
+	CoveredStatements int     `json:"coveredStatements"`
+	TotalStatements   int     `json:"totalStatements"`
+}
+
+// Report is the computed coverage of a table, for embedding coverage-table in other tools: the rows as files and the
+// total as a percentage, along with the statement counts they come from
+type Report struct {
+	Files             []FileCoverage `json:"files"`
+	Total             float64        `json:"total"`
+	CoveredStatements int            `json:"coveredStatements"`
+	TotalStatements   int            `json:"totalStatements"`
+}
+
+// MarshalJSON encodes the report the same way as the json format, with an empty list rather than null when there are
+// no files
+func (r Report) MarshalJSON() ([]byte, error) {
+	if r.Files == nil {
+		r.Files = []FileCoverage{}
+	}
+
+	return json.Marshal(reportFields(r))
+}
+
+// reportFields is a Report without its MarshalJSON, so jsonReport can add fields of its own to it
+type reportFields Report
+
+// jsonReport is a report the way it's written by the json format
+type jsonReport struct {
+	reportFields
+	GeneratedAt *time.Time `json:"generated_at,omitempty"`
+	Commit      string     `json:"commit,omitempty"`
+	Grade       string     `json:"grade,omitempty"`
+}
+
+// Report returns the rows and total of the table, leaving out anything only needed to render it
+func (r Table) Report() Report {
+	out := Report{
+		Files:             make([]FileCoverage, 0, len(r.Rows)),
+		Total:             r.Total.Percent(),
+		CoveredStatements: int(r.Total.Covered),
+		TotalStatements:   int(r.Total.Total),
+	}
+	for _, row := range r.Rows {
+		out.Files = append(out.Files, FileCoverage{
+			Name:              row.Name,
+			Coverage:          row.Percent(),
+			CoveredStatements: int(row.Covered),
+			TotalStatements:   int(row.Total),
+		})
+	}
+
+	return out
+}
+
+// MarshalJSON encodes the rows and total of the table, along with when it was generated if it has a header
+func (r Table) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toJSON())
+}
+
+// toJSON returns the table the way it's written by the json format
+func (r Table) toJSON() jsonReport {
+	out := jsonReport{reportFields: reportFields(r.Report())}
+	if r.Header != nil {
+		out.GeneratedAt = &r.Header.GeneratedAt
+		out.Commit = r.Header.Commit
+	}
+
+	return out
+}
+
+func printJSON(w io.Writer, r Table, opts Options) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if opts.Summary {
+		return enc.Encode(struct {
+			Total float64 `json:"total"`
+		}{r.Total.Percent()})
+	}
+
+	if opts.Grade {
+		out := r.toJSON()
+		out.Grade = opts.grade(r.Total.Percent())
+		return enc.Encode(out)
+	}
+
+	return enc.Encode(r)
+}
+
+func printCSV(w io.Writer, r Table, opts Options) error {
+	cw := csv.NewWriter(w)
+
+	for _, row := range r.Rows {
+		if err := cw.Write([]string{row.Name, opts.formatPercent(row)}); err != nil {
+			return err
+		}
+	}
+
+	if !opts.NoFooter {
+		if err := cw.Write([]string{"Total", opts.Percent(r.Total.Percent())}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func printMarkdown(w io.Writer, r Table, opts Options) error {
+	// Pipes would otherwise end the cell early
+	escape := strings.NewReplacer("|", "\\|")
+
+	// Alignment markers match the terminal table: names on the left, percentages on the right
+	var lines []string
+	if r.Header != nil {
+		lines = append(lines, r.Header.String(), "")
+	}
+	lines = append(lines,
+		"| "+opts.name()+" | Coverage |",
+		"| :--- | ---: |",
+	)
+	for _, row := range r.Rows {
+		lines = append(lines, fmt.Sprintf("| %s | %s |", escape.Replace(row.Name), opts.formatPercent(row)))
+	}
+	if !opts.NoFooter {
+		lines = append(lines, fmt.Sprintf("| **Total** | **%s** |", opts.Percent(r.Total.Percent())))
+	}
+	if r.Hidden > 0 {
+		lines = append(lines, "", "_"+hiddenNote(r)+"_")
+	}
+	if opts.Grade {
+		lines = append(lines, "", fmt.Sprintf("**Grade: %s**", opts.grade(r.Total.Percent())))
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// badgeColors are the shields.io colors for each band returned by colorBand
+var badgeColors = []string{"red", "orange", "yellow", "green", "brightgreen"}
+
+// printBadgeJSON renders the total coverage in the shields.io endpoint format, see https://shields.io/endpoint
+func printBadgeJSON(w io.Writer, r Table, opts Options) error {
+	total := r.Total.Percent()
+
+	// Badges are small, so the message always has a single decimal whatever the precision of the other formats
+	return json.NewEncoder(w).Encode(struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		Label         string `json:"label"`
+		Message       string `json:"message"`
+		Color         string `json:"color"`
+	}{
+		SchemaVersion: 1,
+		Label:         "coverage",
+		Message:       fmt.Sprintf("%.1f%%", total),
+		Color:         badgeColors[opts.colorBand(total)],
+	})
+}
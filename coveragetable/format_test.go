@@ -0,0 +1,173 @@
+package coveragetable
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/tools/cover"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestColorBand(t *testing.T) {
+	tests := []struct {
+		cov  float64
+		want int
+	}{
+		{cov: 0, want: 0},
+		{cov: Coverage{Covered: 29, Total: 100}.Percent(), want: 0},
+		{cov: 39.999, want: 0},
+		{cov: 40, want: 1},
+		{cov: 79.996, want: 2},
+		{cov: 80, want: 3},
+		{cov: 90, want: 4},
+		{cov: 100, want: 4},
+	}
+
+	var opts Options
+	for _, tt := range tests {
+		if got := opts.colorBand(tt.cov); got != tt.want {
+			t.Errorf("colorBand(%v) = %d, want %d", tt.cov, got, tt.want)
+		}
+	}
+}
+
+func TestColorBandUsesExactPercentage(t *testing.T) {
+	opts := Options{Precision: DefaultPrecision}
+
+	// Shown rounded up to the threshold, but still below it
+	if shown := opts.Percent(79.996); shown != "80.00" {
+		t.Fatalf("Percent(79.996) = %s, want 80.00", shown)
+	}
+	if got, want := opts.colorBand(79.996), opts.colorBand(80)-1; got != want {
+		t.Errorf("colorBand(79.996) = %d, want %d", got, want)
+	}
+}
+
+func TestColorBandCustomThresholds(t *testing.T) {
+	// 29 of 100 statements has to come out as exactly 29% to land on the threshold
+	opts := Options{ColorThresholds: [4]float64{29, 50, 70, 90}}
+	tests := []struct {
+		cov  float64
+		want int
+	}{
+		{cov: 28.999, want: 0},
+		{cov: Coverage{Covered: 29, Total: 100}.Percent(), want: 1},
+		{cov: 50, want: 2},
+		{cov: 70, want: 3},
+		{cov: 90, want: 4},
+	}
+
+	for _, tt := range tests {
+		if got := opts.colorBand(tt.cov); got != tt.want {
+			t.Errorf("colorBand(%v) = %d, want %d", tt.cov, got, tt.want)
+		}
+	}
+}
+
+// stableTable builds a table with enough files out of order for map iteration to shuffle anything that isn't sorted
+func stableTable(t *testing.T) Table {
+	t.Helper()
+
+	found := GoFiles{Files: make(map[string]Coverage), Empty: make(map[string]bool)}
+	var profiles []*cover.Profile
+	for i := 30; i > 0; i-- {
+		name := fmt.Sprintf("pkg%d/file%d.go", i%4, i)
+		found.Files[name] = Coverage{}
+		if i%5 == 0 {
+			continue
+		}
+		profiles = append(profiles, profile("example.com/m/"+name, i%3+1, i%2, 1, 0))
+	}
+
+	r, err := BuildTable(NewRepository("/m", "example.com/m"), found, profiles, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header = &Header{GeneratedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Commit: "abc123"}
+
+	return r
+}
+
+func TestStable(t *testing.T) {
+	for _, format := range FormatNames() {
+		t.Run(format, func(t *testing.T) {
+			opts := Options{Format: format, Precision: DefaultPrecision, Stable: true}
+
+			var first, second bytes.Buffer
+			if err := stableTable(t).Render(&first, opts); err != nil {
+				t.Fatal(err)
+			}
+			// Timestamps only change from one second to the next
+			if format == "cobertura" {
+				time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			}
+			if err := stableTable(t).Render(&second, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Errorf("rendered differently the second time:\n%s\nthen:\n%s", first.String(), second.String())
+			}
+		})
+	}
+}
+
+func TestFuncRowsStayOnOneLine(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"internal/coverage/baseline/baseline.go": "package baseline\n\nfunc LoadBaseline() int {\n\treturn 1\n}\n\nfunc NewBaselineFromReport() int {\n\treturn 2\n}\n",
+	})
+	found := GoFiles{Files: map[string]Coverage{"internal/coverage/baseline/baseline.go": {}}}
+	profiles := []*cover.Profile{{
+		FileName: "example.com/m/internal/coverage/baseline/baseline.go",
+		Mode:     "set",
+		Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 26, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+			{StartLine: 7, StartCol: 34, EndLine: 9, EndCol: 2, NumStmt: 1, Count: 0},
+		},
+	}}
+	r, err := BuildTable(NewRepository(root, "example.com/m"), found, profiles, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err = GroupByFunc(root, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, Options{Precision: DefaultPrecision}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, row := range r.Rows {
+		if !strings.Contains(out, "| "+row.Name+" ") {
+			t.Errorf("row %q isn't on a line of its own:\n%s", row.Name, out)
+		}
+	}
+	// Borders, header, two rows, and the footer
+	if lines := strings.Count(out, "\n"); lines != 8 {
+		t.Errorf("table has %d lines, want 8:\n%s", lines, out)
+	}
+}
+
+func TestHeaderNamesRows(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{opts: Options{}, want: "| FILE | COVERAGE (%) |"},
+		{opts: Options{Name: "Package", Verbose: true}, want: "| PACKAGE | STMTS | COVERAGE (%) |"},
+		{opts: Options{Name: "Directory", Format: "markdown"}, want: "| Directory | Coverage |"},
+	}
+
+	for _, tt := range tests {
+		tt.opts.Precision = DefaultPrecision
+		var buf bytes.Buffer
+		if err := stableTable(t).Render(&buf, tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(strings.Join(strings.Fields(buf.String()), " "), tt.want) {
+			t.Errorf("header of %+v isn't %q:\n%s", tt.opts, tt.want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package coveragetable
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"golang.org/x/tools/cover"
+	"path/filepath"
+)
+
+// GroupByFunc splits every file of a report into a row per function, in the order they appear in each file. The
+// source files are read from root, unless a row already has an absolute name.
+func GroupByFunc(root string, r Table) (Table, error) {
+	grouped := Table{Total: r.Total, blocks: r.blocks, weakLimit: r.weakLimit}
+
+	for _, fileRow := range r.Rows {
+		// Rows named by AbsNames are already where the file is
+		p := fileRow.Name
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, filepath.FromSlash(p))
+		}
+		funcs, err := findFuncs(p)
+		if err != nil {
+			return Table{}, err
+		}
+
+		for _, fn := range funcs {
+			grouped.Rows = append(grouped.Rows, Row{
+				Name:     fmt.Sprintf("%s:%d: %s", fileRow.Name, fn.startLine, fn.name),
+				Coverage: fn.coverage(r.blocks[fileRow.Name], r.weakLimit),
+			})
+		}
+	}
+
+	return grouped, nil
+}
+
+// funcExtent is the name and position of a function declaration in a go file
+type funcExtent struct {
+	name      string
+	startLine int
+	startCol  int
+	endLine   int
+	endCol    int
+}
+
+// findFuncs returns the functions declared in the go file at p, in the order they appear
+func findFuncs(p string) ([]funcExtent, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, p, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var funcs []funcExtent
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+		funcs = append(funcs, funcExtent{
+			name:      funcName(fn),
+			startLine: start.Line,
+			startCol:  start.Column,
+			endLine:   end.Line,
+			endCol:    end.Column,
+		})
+	}
+
+	return funcs, nil
+}
+
+// funcName returns the name of a function the way 'go tool cover -func' shows it, including the receiver for methods
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		return fmt.Sprintf("(*%s).%s", typeName(star.X), fn.Name.Name)
+	}
+
+	return fmt.Sprintf("%s.%s", typeName(recv), fn.Name.Name)
+}
+
+// typeName returns the name of a receiver type, dropping any type parameters
+func typeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.IndexExpr:
+		return typeName(expr.X)
+	case *ast.IndexListExpr:
+		// More than one type parameter
+		return typeName(expr.X)
+	}
+
+	return "?"
+}
+
+// coverage counts the statements of the profile blocks that fall within the function. Blocks are expected to be
+// sorted by position, as they are in a parsed profile.
+func (f funcExtent) coverage(blocks []cover.ProfileBlock, weakLimit int) Coverage {
+	var c Coverage
+
+	for _, block := range blocks {
+		if block.StartLine > f.endLine || (block.StartLine == f.endLine && block.StartCol >= f.endCol) {
+			break
+		}
+		if block.EndLine < f.startLine || (block.EndLine == f.startLine && block.EndCol <= f.startCol) {
+			continue
+		}
+
+		c.addBlock(block, weakLimit)
+	}
+
+	return c
+}
@@ -0,0 +1,141 @@
+package coveragetable
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// gitignore holds the rules of every .gitignore file that applies to the directories walked so far
+type gitignore struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is a single line of a .gitignore file
+type ignoreRule struct {
+	// dir is the directory of the .gitignore file, patterns only apply below it
+	dir     string
+	pattern string
+	// negate is set for patterns starting with '!', which include what an earlier pattern excluded
+	negate bool
+	// dirOnly is set for patterns ending in '/', which only match directories
+	dirOnly bool
+	// anchored is set for patterns with a '/' anywhere but at the end, which are matched against the path relative to
+	// dir instead of just the name
+	anchored bool
+}
+
+// loadAncestors loads the rules that apply to dir from the directories above it, up to the top of the git repository
+// it's in. Outside of a git repository there's nothing to load, as those files wouldn't mean anything to git either.
+func (g *gitignore) loadAncestors(dir string) error {
+	var parents []string
+	top := ""
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			top = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return nil
+		}
+		d = parent
+		parents = append(parents, d)
+	}
+
+	// Rules further down take precedence, so the outermost ones go first
+	if err := g.loadFile(top, filepath.Join(top, ".git", "info", "exclude")); err != nil {
+		return err
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		if err := g.load(parents[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load adds the rules of the .gitignore file in dir, if there is one
+func (g *gitignore) load(dir string) error {
+	return g.loadFile(dir, filepath.Join(dir, ".gitignore"))
+}
+
+// loadFile adds the rules in the named file, which apply below dir
+func (g *gitignore) loadFile(dir, name string) error {
+	data, err := os.ReadFile(name)
+	// In a worktree .git is a file, so there's no info/exclude below it
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r := ignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		// A backslash escapes a leading '#' or '!'
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+
+		g.rules = append(g.rules, r)
+	}
+
+	return nil
+}
+
+// ignored reports whether p is ignored by the rules loaded so far. Like git, the last rule matching p decides.
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	ignored := false
+	for _, r := range g.rules {
+		if r.matches(p, isDir) {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
+
+func (r ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(r.dir, p)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+
+	if r.anchored {
+		return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
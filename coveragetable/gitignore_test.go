@@ -0,0 +1,143 @@
+package coveragetable
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// withStatement is a go file with a statement to cover, for fixtures that only care about the file names
+const withStatement = "package p\n\nfunc F() {\n\tprintln()\n}\n"
+
+func TestRespectGitignore(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "name anywhere",
+			files: map[string]string{
+				".gitignore":     "# generated\n*.pb.go\n",
+				"api.go":         "",
+				"api.pb.go":      "",
+				"v1/v1.pb.go":    "",
+				"v1/handlers.go": "",
+			},
+			want: []string{"api.go", "v1/handlers.go"},
+		},
+		{
+			name: "negation",
+			files: map[string]string{
+				".gitignore":      "*_gen.go\n!keep_gen.go\n",
+				"a_gen.go":        "",
+				"keep_gen.go":     "",
+				"sub/b_gen.go":    "",
+				"sub/keep_gen.go": "",
+			},
+			want: []string{"keep_gen.go", "sub/keep_gen.go"},
+		},
+		{
+			name: "anchored",
+			files: map[string]string{
+				".gitignore":     "/gen\n/root.go\n",
+				"gen/gen.go":     "",
+				"root.go":        "",
+				"sub/gen/gen.go": "",
+				"sub/root.go":    "",
+			},
+			want: []string{"sub/gen/gen.go", "sub/root.go"},
+		},
+		{
+			name: "directories only",
+			files: map[string]string{
+				".gitignore":       "build/\n",
+				"build/out.go":     "",
+				"sub/build/out.go": "",
+				"tools/build":      "",
+				"tools/build.go":   "",
+			},
+			want: []string{"tools/build.go"},
+		},
+		{
+			name: "double star",
+			files: map[string]string{
+				".gitignore":                           "**/fixtures\ndocs/**\nexamples/**/main_test_helper.go\n",
+				"fixtures/a.go":                        "",
+				"deep/er/fixtures/b.go":                "",
+				"docs/doc.go":                          "",
+				"docs/more/more.go":                    "",
+				"examples/main_test_helper.go":         "",
+				"examples/one/two/main_test_helper.go": "",
+				"examples/one/example.go":              "",
+				"notdocs/docs.go":                      "",
+			},
+			want: []string{"examples/one/example.go", "notdocs/docs.go"},
+		},
+		{
+			name: "nested files",
+			files: map[string]string{
+				".gitignore":     "local.go\ncache.go\n",
+				"keep.go":        "",
+				"local.go":       "",
+				"sub/.gitignore": "!cache.go\nsub.go\n",
+				"sub/cache.go":   "",
+				"sub/sub.go":     "",
+				"other/cache.go": "",
+				"other/sub.go":   "",
+			},
+			want: []string{"keep.go", "other/sub.go", "sub/cache.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string]string, len(tt.files))
+			for name, content := range tt.files {
+				if filepath.Ext(name) == ".go" {
+					content = withStatement
+				}
+				files[name] = content
+			}
+			dir := writeFiles(t, files)
+
+			found := walk(t, dir, WalkOptions{RespectGitignore: true})
+			var got []string
+			for name := range found.Files {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("found %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespectGitignoreAncestors(t *testing.T) {
+	// Rules from above the walked directory count up to the top of the git repository, along with .git/info/exclude
+	outside := writeFiles(t, map[string]string{
+		".gitignore":                  "*.go\n",
+		"repo/.gitignore":             "ignored.go\n",
+		"repo/.git/info/exclude":      "excluded.go\n",
+		"repo/module/.gitignore":      "!reincluded.go\n",
+		"repo/module/pkg/kept.go":     withStatement,
+		"repo/module/pkg/ignored.go":  withStatement,
+		"repo/module/pkg/excluded.go": withStatement,
+	})
+
+	found := walk(t, filepath.Join(outside, "repo", "module", "pkg"), WalkOptions{RespectGitignore: true})
+	var got []string
+	for name := range found.Files {
+		got = append(got, name)
+	}
+	if want := []string{"kept.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("found %v, want %v", got, want)
+	}
+
+	found = walk(t, filepath.Join(outside, "repo", "module", "pkg"), WalkOptions{})
+	if len(found.Files) != 3 {
+		t.Errorf("found %d files without RespectGitignore, want all 3", len(found.Files))
+	}
+}
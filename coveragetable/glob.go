@@ -0,0 +1,80 @@
+package coveragetable
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the slash-separated name matches pattern. Each segment of the pattern is matched with
+// path.Match, except for "**" which matches any number of directories. Patterns without a slash, like "*.pb.go", are
+// matched against the base name so they apply in every directory.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// matchAny reports whether name matches at least one of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateGlob returns an error if pattern is malformed
+func ValidateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FileFilter decides which files are shown in the table and counted towards the total
+type FileFilter struct {
+	Includes []string
+	Excludes []string
+}
+
+// Keep reports whether name passes the filter. When there are includes a file must match at least one of them, and
+// excludes always take precedence.
+func (f FileFilter) Keep(name string) bool {
+	if len(f.Includes) > 0 && !matchAny(f.Includes, name) {
+		return false
+	}
+
+	return !matchAny(f.Excludes, name)
+}
@@ -0,0 +1,43 @@
+package coveragetable
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Header tells when, and from which commit, a report was generated
+type Header struct {
+	GeneratedAt time.Time
+	// Commit is the short hash of the git commit checked out, or empty when it isn't known
+	Commit string
+}
+
+// NewHeader returns the header for a report generated from dir right now. The commit is left out when dir isn't in a
+// git repository, or git isn't installed.
+func NewHeader(dir string) Header {
+	// Nobody needs to know the time of a report down to the nanosecond
+	h := Header{GeneratedAt: time.Now().Truncate(time.Second)}
+
+	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		debugf("not adding the commit to the header: %s", err)
+		return h
+	}
+	h.Commit = strings.TrimSpace(string(out))
+
+	return h
+}
+
+// String returns the header as the line above the table
+func (h Header) String() string {
+	s := fmt.Sprintf("Generated at %s", h.GeneratedAt.Format(time.RFC3339))
+	if h.Commit != "" {
+		s += fmt.Sprintf(" from commit %s", h.Commit)
+	}
+
+	return s
+}
@@ -0,0 +1,110 @@
+package coveragetable
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// HistoryEntry is the total coverage of a single run, as stored in a history file
+type HistoryEntry struct {
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit,omitempty"`
+	Total  float64   `json:"total"`
+}
+
+// AppendHistory adds an entry to the end of the named history file, creating it when needed. The entry is written as
+// a single line in a single write to a file opened for appending, so concurrent runs can't interleave their entries.
+func AppendHistory(name string, e HistoryEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadHistory reads every entry from the named history file, oldest first
+func LoadHistory(name string) ([]HistoryEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, s.Err()
+}
+
+// sparkBars are the bars of a sparkline, from lowest to highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline returns the totals of entries as a sparkline scaled between the lowest and highest of them, followed by
+// the latest total and how much it changed since the one before, both with precision decimals
+func Sparkline(entries []HistoryEntry, precision int) string {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	low, high := entries[0].Total, entries[0].Total
+	for _, e := range entries {
+		if e.Total < low {
+			low = e.Total
+		}
+		if e.Total > high {
+			high = e.Total
+		}
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		// A flat line sits in the middle, rather than looking like there's no coverage at all
+		bar := len(sparkBars) / 2
+		if high > low {
+			bar = int((e.Total - low) / (high - low) * float64(len(sparkBars)-1))
+		}
+		b.WriteRune(sparkBars[bar])
+	}
+
+	last := entries[len(entries)-1].Total
+	fmt.Fprintf(&b, " %.*f", precision, last)
+	if len(entries) > 1 {
+		// A change too small to show is no change, rather than an arrow next to zero
+		change := last - entries[len(entries)-2].Total
+		switch shown := math.Round(change * math.Pow10(precision)); {
+		case shown > 0:
+			fmt.Fprintf(&b, " (↑ %.*f)", precision, change)
+		case shown < 0:
+			fmt.Fprintf(&b, " (↓ %.*f)", precision, -change)
+		default:
+			b.WriteString(" (unchanged)")
+		}
+	}
+
+	return b.String()
+}
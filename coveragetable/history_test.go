@@ -0,0 +1,152 @@
+package coveragetable
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAppendHistory(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history.jsonl")
+	entries := []HistoryEntry{
+		{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Commit: "abc123", Total: 61.5},
+		{Time: time.Date(2020, 1, 3, 3, 4, 5, 0, time.UTC), Total: 62.25},
+	}
+
+	// Appended one at a time, the way each run adds its own
+	for _, e := range entries {
+		if err := AppendHistory(name, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := LoadHistory(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("LoadHistory() = %+v, want %+v", got, entries)
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []HistoryEntry
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "blank lines",
+			content: "\n{\"time\":\"2020-01-02T03:04:05Z\",\"total\":50}\n  \n",
+			want:    []HistoryEntry{{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Total: 50}},
+		},
+		{
+			name:    "not json",
+			content: "{\"time\":\"2020-01-02T03:04:05Z\",\"total\":50}\n50\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "history.jsonl")
+			if err := os.WriteFile(name, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadHistory(name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadHistory() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadHistory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHistoryMissing(t *testing.T) {
+	if _, err := LoadHistory(filepath.Join(t.TempDir(), "history.jsonl")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadHistory() error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	totals := func(totals ...float64) []HistoryEntry {
+		entries := make([]HistoryEntry, len(totals))
+		for i, total := range totals {
+			entries[i] = HistoryEntry{Total: total}
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name      string
+		entries   []HistoryEntry
+		precision int
+		want      string
+	}{
+		{
+			name: "nothing",
+			want: "",
+		},
+		{
+			name:      "single run",
+			entries:   totals(71.2),
+			precision: 2,
+			want:      "▅ 71.20",
+		},
+		{
+			name:      "up",
+			entries:   totals(60, 70, 67.8, 71.2),
+			precision: 2,
+			want:      "▁▇▅█ 71.20 (↑ 3.40)",
+		},
+		{
+			name:      "up after a drop",
+			entries:   totals(80, 60, 75),
+			precision: 2,
+			want:      "█▁▆ 75.00 (↑ 15.00)",
+		},
+		{
+			name:      "down",
+			entries:   totals(75, 80, 60),
+			precision: 1,
+			want:      "▆█▁ 60.0 (↓ 20.0)",
+		},
+		{
+			name:      "flat",
+			entries:   totals(50, 50),
+			precision: 2,
+			want:      "▅▅ 50.00 (unchanged)",
+		},
+		{
+			name:      "too small to show",
+			entries:   totals(50, 50.004),
+			precision: 2,
+			want:      "▁█ 50.00 (unchanged)",
+		},
+		{
+			name:      "shown with more decimals",
+			entries:   totals(50, 50.004),
+			precision: 3,
+			want:      "▁█ 50.004 (↑ 0.004)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sparkline(tt.entries, tt.precision); got != tt.want {
+				t.Errorf("Sparkline() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
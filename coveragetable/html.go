@@ -0,0 +1,99 @@
+package coveragetable
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlColors are the CSS colors for each band returned by colorBand, matching the terminal colors
+var htmlColors = []string{"#e74c3c", "#c0392b", "#f1c40f", "#27ae60", "#2ecc71"}
+
+// htmlTemplate is kept inline so the binary stays a single self-contained file
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Coverage {{.Total}}%</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; }
+table { border-collapse: collapse; min-width: 40em; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; }
+th { cursor: pointer; text-align: left; user-select: none; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #eee; width: 12em; height: 0.8em; }
+.bar div { height: 100%; }
+</style>
+</head>
+<body>
+<h1>Coverage: <span style="color: {{.TotalColor}}">{{.Total}}%</span></h1>
+<table id="coverage">
+<thead>
+<tr><th data-type="text">File</th><th class="num" data-type="number">Coverage (%)</th><th></th></tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr>
+<td>{{.Name}}</td>
+<td class="num" data-value="{{.Value}}" style="color: {{.Color}}">{{.Percent}}</td>
+<td><div class="bar"><div style="width: {{.Value}}%; background: {{.Color}}"></div></div></td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+document.querySelectorAll("#coverage th[data-type]").forEach(function (th, column) {
+  var ascending = true;
+  th.addEventListener("click", function () {
+    var body = document.querySelector("#coverage tbody");
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[column], y = b.cells[column];
+      var order = th.dataset.type === "number"
+        ? parseFloat(x.dataset.value) - parseFloat(y.dataset.value)
+        : x.textContent.localeCompare(y.textContent);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+    ascending = !ascending;
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+type htmlRow struct {
+	Name    string
+	Percent string
+	Value   float64
+	Color   string
+}
+
+func printHTML(w io.Writer, r Table, opts Options) error {
+	total := r.Total.Percent()
+	data := struct {
+		Total      string
+		TotalColor string
+		Rows       []htmlRow
+	}{
+		Total:      opts.Percent(total),
+		TotalColor: htmlColors[opts.colorBand(total)],
+	}
+
+	for _, row := range r.Rows {
+		cov := row.Percent()
+		color := htmlColors[opts.colorBand(cov)]
+		if row.Empty {
+			color = "inherit"
+		}
+
+		data.Rows = append(data.Rows, htmlRow{
+			Name:    row.Name,
+			Percent: opts.formatPercent(row),
+			Value:   cov,
+			Color:   color,
+		})
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
@@ -0,0 +1,67 @@
+package coveragetable
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/tools/cover"
+	"io"
+	"sort"
+)
+
+// printLCOV renders the lines of every file as an LCOV tracefile, see the geninfo man page for the format
+func printLCOV(w io.Writer, r Table, opts Options) error {
+	bw := bufio.NewWriter(w)
+
+	for _, row := range r.Rows {
+		counts := lineCounts(r.blocks[row.Name])
+
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", row.Name)
+		for _, line := range counts.lines() {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, counts[line])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(counts), counts.hit())
+	}
+
+	return bw.Flush()
+}
+
+// lineHits maps line numbers to the number of times they ran
+type lineHits map[int]int
+
+// lineCounts spreads the counts of profile blocks over the lines they span, for formats that only know about lines.
+// Each line gets the highest count of the blocks it's part of.
+func lineCounts(blocks []cover.ProfileBlock) lineHits {
+	counts := make(lineHits)
+	for _, block := range blocks {
+		for line := block.StartLine; line <= block.EndLine; line++ {
+			if c, ok := counts[line]; !ok || block.Count > c {
+				counts[line] = block.Count
+			}
+		}
+	}
+
+	return counts
+}
+
+// lines returns the line numbers in order
+func (h lineHits) lines() []int {
+	lines := make([]int, 0, len(h))
+	for line := range h {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// hit returns the number of lines that ran at least once
+func (h lineHits) hit() int {
+	hit := 0
+	for _, c := range h {
+		if c > 0 {
+			hit++
+		}
+	}
+
+	return hit
+}
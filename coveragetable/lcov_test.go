@@ -0,0 +1,32 @@
+package coveragetable
+
+import (
+	"bytes"
+	"golang.org/x/tools/cover"
+	"os"
+	"testing"
+)
+
+func TestLCOV(t *testing.T) {
+	profiles, err := cover.ParseProfiles("testdata/lcov.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := GoFiles{Files: map[string]Coverage{"calc/calc.go": {}, "strs/strs.go": {}}}
+	r, err := BuildTable(NewRepository("/m", "example.com/m"), found, profiles, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got bytes.Buffer
+	if err := r.Render(&got, Options{Format: "lcov"}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/lcov.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("lcov output differs from testdata/lcov.golden:\n%s", got.String())
+	}
+}
@@ -0,0 +1,22 @@
+package coveragetable
+
+// Level is the importance of a diagnostic passed to Logf
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Logf is called with every diagnostic, like why a file was left out of the report. It does nothing unless set.
+var Logf = func(level Level, format string, args ...interface{}) {}
+
+func debugf(format string, args ...interface{}) {
+	Logf(LevelDebug, format, args...)
+}
+
+func warnf(format string, args ...interface{}) {
+	Logf(LevelWarn, format, args...)
+}
@@ -0,0 +1,67 @@
+package coveragetable
+
+import (
+	"fmt"
+	"golang.org/x/tools/cover"
+	"sort"
+)
+
+// blockKey identifies a block within a file, regardless of how often it ran
+type blockKey struct {
+	StartLine, StartCol, EndLine, EndCol, NumStmt int
+}
+
+// MergeProfiles combines the profiles of several test runs into a single profile per file. Counts of the same block
+// are summed, so a block covered by any of the runs is covered in the result. Profiles in set mode can't be mixed with
+// the count based modes, as their counts don't mean the same thing.
+func MergeProfiles(profiles []*cover.Profile) ([]*cover.Profile, error) {
+	merged := make(map[string]*cover.Profile)
+	indexes := make(map[string]map[blockKey]int)
+	var names []string
+
+	for _, p := range profiles {
+		m, ok := merged[p.FileName]
+		if !ok {
+			m = &cover.Profile{FileName: p.FileName, Mode: p.Mode}
+			merged[p.FileName] = m
+			indexes[p.FileName] = make(map[blockKey]int)
+			names = append(names, p.FileName)
+		}
+		if m.Mode != p.Mode && (m.Mode == "set" || p.Mode == "set") {
+			return nil, fmt.Errorf("%s has coverage in both %s and %s mode", p.FileName, m.Mode, p.Mode)
+		}
+
+		index := indexes[p.FileName]
+		for _, b := range p.Blocks {
+			key := blockKey{b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt}
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(m.Blocks)
+				m.Blocks = append(m.Blocks, b)
+				continue
+			}
+
+			if m.Mode == "set" {
+				if b.Count > 0 {
+					m.Blocks[i].Count = 1
+				}
+			} else {
+				m.Blocks[i].Count += b.Count
+			}
+		}
+	}
+
+	result := make([]*cover.Profile, 0, len(names))
+	for _, name := range names {
+		m := merged[name]
+		// Blocks are expected in order of their position, as cover.ParseProfiles returns them. Blocks starting at the same
+		// place keep the order they were first seen in.
+		sort.SliceStable(m.Blocks, func(i, j int) bool {
+			bi, bj := m.Blocks[i], m.Blocks[j]
+			return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
+		})
+		result = append(result, m)
+	}
+
+	return result, nil
+}
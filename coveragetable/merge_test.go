@@ -0,0 +1,126 @@
+package coveragetable
+
+import (
+	"golang.org/x/tools/cover"
+	"reflect"
+	"testing"
+)
+
+// block returns a block from the start of line start to the end of line end
+func block(start, end, statements, count int) cover.ProfileBlock {
+	return cover.ProfileBlock{StartLine: start, StartCol: 1, EndLine: end, EndCol: 2, NumStmt: statements, Count: count}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []*cover.Profile
+		want     []*cover.Profile
+	}{
+		{
+			name: "counts of the same block are summed",
+			profiles: []*cover.Profile{
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 3), block(3, 4, 2, 0)}},
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 2), block(3, 4, 2, 0)}},
+			},
+			want: []*cover.Profile{
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 5), block(3, 4, 2, 0)}},
+			},
+		},
+		{
+			name: "a block covered by either run is covered",
+			profiles: []*cover.Profile{
+				{FileName: "a.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(1, 2, 1, 0), block(3, 4, 2, 7)}},
+				{FileName: "a.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1), block(3, 4, 2, 0)}},
+			},
+			want: []*cover.Profile{
+				{FileName: "a.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1), block(3, 4, 2, 7)}},
+			},
+		},
+		{
+			name: "set mode stays at one",
+			profiles: []*cover.Profile{
+				{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1), block(3, 4, 2, 0)}},
+				{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1), block(3, 4, 2, 0)}},
+				{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1), block(3, 4, 2, 1)}},
+			},
+			want: []*cover.Profile{
+				{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1), block(3, 4, 2, 1)}},
+			},
+		},
+		{
+			name: "count and atomic mix",
+			profiles: []*cover.Profile{
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 2)}},
+				{FileName: "a.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(1, 2, 1, 3)}},
+			},
+			want: []*cover.Profile{
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 5)}},
+			},
+		},
+		{
+			name: "overlapping blocks that aren't the same are kept apart, in order",
+			profiles: []*cover.Profile{
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(5, 9, 3, 1)}},
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 6, 2, 0), block(5, 9, 4, 1)}},
+			},
+			want: []*cover.Profile{
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 6, 2, 0), block(5, 9, 3, 1), block(5, 9, 4, 1)}},
+			},
+		},
+		{
+			name: "files in only one profile are kept as they are",
+			profiles: []*cover.Profile{
+				{FileName: "b.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 4)}},
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1)}},
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1)}},
+				{FileName: "c.go", Mode: "count", Blocks: []cover.ProfileBlock{block(3, 4, 2, 0)}},
+			},
+			want: []*cover.Profile{
+				{FileName: "b.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 4)}},
+				{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 2)}},
+				{FileName: "c.go", Mode: "count", Blocks: []cover.ProfileBlock{block(3, 4, 2, 0)}},
+			},
+		},
+		{
+			name: "nothing",
+			want: []*cover.Profile{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeProfiles(tt.profiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeProfiles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeProfilesModeMismatch(t *testing.T) {
+	for _, mode := range []string{"count", "atomic"} {
+		profiles := []*cover.Profile{
+			{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1)}},
+			{FileName: "a.go", Mode: mode, Blocks: []cover.ProfileBlock{block(1, 2, 1, 3)}},
+		}
+		if _, err := MergeProfiles(profiles); err == nil {
+			t.Errorf("MergeProfiles() of set and %s mode returned no error", mode)
+		}
+	}
+}
+
+func TestMergeProfilesLeavesInputsAlone(t *testing.T) {
+	first := &cover.Profile{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1)}}
+	second := &cover.Profile{FileName: "a.go", Mode: "count", Blocks: []cover.ProfileBlock{block(1, 2, 1, 1)}}
+
+	if _, err := MergeProfiles([]*cover.Profile{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	if first.Blocks[0].Count != 1 {
+		t.Errorf("MergeProfiles() changed the count of the first profile to %d", first.Blocks[0].Count)
+	}
+}
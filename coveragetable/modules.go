@@ -0,0 +1,398 @@
+package coveragetable
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/cover"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoModules is returned by FindModules for a directory that isn't in a module at all, which may still be a package
+// in GOPATH, see GOPATHRepository
+var ErrNoModules = errors.New("no go.mod or go.work file found")
+
+// Repository holds the go modules found in the directory coverage-table was run in
+type Repository struct {
+	// modules maps the path of each module to its directory, relative to the repository root
+	modules map[string]string
+	// unused holds the directories of the modules under the repository root that its go.work file doesn't use
+	unused []string
+	// replaced maps the module paths replaced by a directory in the repository to that directory, like modules. Files
+	// of a replaced module are named by the path it's replacing, which may not be the one in its own go.mod.
+	replaced map[string]string
+	// gopath is set for a directory that isn't a module, with the tests run in GOPATH mode
+	gopath bool
+	// root is the slash-separated absolute path of a directory that isn't a module. Outside of GOPATH, 'go test' names
+	// the files in the profile by their absolute path rather than an import path.
+	root string
+}
+
+// NewRepository returns the repository for root when it isn't a module, with prefix as the import path of root
+// itself. 'go test' has to run in GOPATH mode for it, see InGOPATH.
+func NewRepository(root, prefix string) Repository {
+	return Repository{modules: map[string]string{prefix: "."}, gopath: true, root: filepath.ToSlash(root)}
+}
+
+// GOPATHRepository returns the repository for root as a package in one of the directories of gopath, a list like the
+// GOPATH environment variable, with its import path being where it is below 'src'. It returns false when root isn't
+// in GOPATH.
+func GOPATHRepository(root, gopath string) (Repository, bool) {
+	for _, dir := range filepath.SplitList(gopath) {
+		if dir == "" {
+			continue
+		}
+
+		rel, err := filepath.Rel(filepath.Join(dir, "src"), root)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		return NewRepository(root, filepath.ToSlash(rel)), true
+	}
+
+	return Repository{}, false
+}
+
+// WithModulePath returns the repository with the module in dir known by modPath instead of the path in its go.mod,
+// for profiles that were written with a different module path
+func (r Repository) WithModulePath(dir, modPath string) (Repository, error) {
+	modules := make(map[string]string, len(r.modules))
+	found := false
+	for mod, d := range r.modules {
+		if d == dir {
+			mod = modPath
+			found = true
+		}
+		modules[mod] = d
+	}
+	if !found {
+		return r, fmt.Errorf("no module in %s", dir)
+	}
+	r.modules = modules
+
+	return r, nil
+}
+
+// InGOPATH reports whether the repository is a package in GOPATH rather than a module
+func (r Repository) InGOPATH() bool {
+	return r.gopath
+}
+
+// FindModules finds the modules in root. When root has a go.work file only the modules it uses are picked up,
+// otherwise every go.mod under root is.
+func FindModules(root string) (Repository, error) {
+	repo := Repository{modules: make(map[string]string), replaced: make(map[string]string)}
+
+	dirs, err := workspaceDirs(root)
+	if err != nil {
+		return repo, err
+	}
+	all, err := moduleDirs(root)
+	if err != nil {
+		return repo, err
+	}
+	if dirs == nil {
+		dirs = all
+	} else {
+		// 'go test' doesn't run in the modules a workspace doesn't use, so their files can't be covered
+		used := make(map[string]bool, len(dirs))
+		for _, dir := range dirs {
+			used[dir] = true
+		}
+		for _, dir := range all {
+			if !used[dir] {
+				repo.unused = append(repo.unused, dir)
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		bytes, err := os.ReadFile(filepath.Join(root, dir, "go.mod"))
+		if err != nil {
+			return repo, err
+		}
+
+		repo.modules[modfile.ModulePath(bytes)] = dir
+
+		if err := repo.addReplaces(root, dir, bytes); err != nil {
+			return repo, fmt.Errorf("reading %s: %w", path.Join(dir, "go.mod"), err)
+		}
+	}
+
+	// Like the go tool, a directory inside of a module belongs to that module, with its import path below the module's
+	if len(repo.modules) == 0 {
+		mod, err := enclosingModule(root)
+		if err != nil {
+			return repo, err
+		}
+		if mod != "" {
+			repo.modules[mod] = "."
+		}
+	}
+
+	if len(repo.modules) == 0 {
+		return repo, ErrNoModules
+	}
+
+	return repo, nil
+}
+
+// enclosingModule walks up from dir to the nearest go.mod, returning the import path dir has in that module, or an
+// empty string when there is no go.mod above dir
+func enclosingModule(dir string) (string, error) {
+	var below []string
+	for d := dir; ; d = filepath.Dir(d) {
+		bytes, err := os.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			return path.Join(append([]string{modfile.ModulePath(bytes)}, below...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		// Reached the root of the file system without finding one
+		if filepath.Dir(d) == d {
+			return "", nil
+		}
+		below = append([]string{filepath.Base(d)}, below...)
+	}
+}
+
+// addReplaces adds the modules replaced by a directory in root to the repository, from the go.mod file of the module
+// in dir. Replacements outside of root aren't tested, so their files can't be in the profile.
+func (r *Repository) addReplaces(root, dir string, data []byte) error {
+	// The lax parser keeps the syntax of replace directives without checking them, just like with go.work files
+	mod, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return err
+	}
+
+	addReplace := func(tokens []string) {
+		// Either 'old => new' or 'old version => new', with a version after new only for a module rather than a directory
+		arrow := -1
+		for i, token := range tokens {
+			if token == "=>" {
+				arrow = i
+			}
+		}
+		if arrow < 1 || arrow > 2 || len(tokens) != arrow+2 {
+			return
+		}
+
+		old, target := tokens[0], tokens[arrow+1]
+		if unquoted, err := strconv.Unquote(old); err == nil {
+			old = unquoted
+		}
+		if unquoted, err := strconv.Unquote(target); err == nil {
+			target = unquoted
+		}
+		if !modfile.IsDirectoryPath(target) {
+			return
+		}
+
+		if filepath.IsAbs(target) {
+			rel, err := filepath.Rel(root, target)
+			if err != nil {
+				return
+			}
+			target = filepath.ToSlash(rel)
+		} else {
+			target = path.Join(dir, filepath.ToSlash(target))
+		}
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return
+		}
+
+		r.replaced[old] = target
+	}
+
+	for _, stmt := range mod.Syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			if len(stmt.Token) > 0 && stmt.Token[0] == "replace" {
+				addReplace(stmt.Token[1:])
+			}
+		case *modfile.LineBlock:
+			if len(stmt.Token) > 0 && stmt.Token[0] == "replace" {
+				for _, line := range stmt.Line {
+					addReplace(line.Token)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// workspaceDirs returns the directories used by the go.work file in root, or nil if there isn't one
+func workspaceDirs(root string) ([]string, error) {
+	bytes, err := os.ReadFile(filepath.Join(root, "go.work"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A go.work file shares its syntax with go.mod, so the lax parser can read it and leave the 'use' directives to us
+	work, err := modfile.ParseLax("go.work", bytes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{}
+	addDir := func(tokens []string) {
+		if len(tokens) != 1 {
+			return
+		}
+
+		dir := tokens[0]
+		if unquoted, err := strconv.Unquote(dir); err == nil {
+			dir = unquoted
+		}
+
+		// Modules outside of root aren't tested by 'go test ./...', so they can't be in the profile
+		dir = path.Clean(filepath.ToSlash(dir))
+		if dir == ".." || strings.HasPrefix(dir, "../") || path.IsAbs(dir) {
+			return
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	for _, stmt := range work.Syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			if len(stmt.Token) > 0 && stmt.Token[0] == "use" {
+				addDir(stmt.Token[1:])
+			}
+		case *modfile.LineBlock:
+			if len(stmt.Token) > 0 && stmt.Token[0] == "use" {
+				for _, line := range stmt.Line {
+					addDir(line.Token)
+				}
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+// moduleDirs walks root for directories containing a go.mod file
+func moduleDirs(root string) ([]string, error) {
+	var dirs []string
+
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip the same directories FindGoFiles does
+		if fi.IsDir() && p != root && (strings.HasPrefix(fi.Name(), ".") || fi.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+
+		if !fi.IsDir() && fi.Name() == "go.mod" {
+			dir, err := filepath.Rel(root, filepath.Dir(p))
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, filepath.ToSlash(dir))
+		}
+
+		return nil
+	})
+
+	return dirs, err
+}
+
+// Tested reports whether the file with the slash-separated name, relative to the repository root, is in one of the
+// modules 'go test' runs in. Like for the go tool, a file is in the module with the nearest go.mod above it.
+func (r Repository) Tested(name string) bool {
+	best, tested := -1, false
+	check := func(dir string, isTested bool) {
+		if dir != "." && !strings.HasPrefix(name, dir+"/") {
+			return
+		}
+		depth := 0
+		if dir != "." {
+			depth = strings.Count(dir, "/") + 1
+		}
+		if depth > best {
+			best, tested = depth, isTested
+		}
+	}
+
+	for _, dir := range r.modules {
+		check(dir, true)
+	}
+	for _, dir := range r.unused {
+		check(dir, false)
+	}
+
+	return tested
+}
+
+// TestDirs returns the directories 'go test' needs to run in to cover every module, sorted by name. Even in a
+// workspace './...' doesn't cross module boundaries, so each module is tested on its own.
+func (r Repository) TestDirs() []string {
+	dirs := make([]string, 0, len(r.modules))
+	for _, dir := range r.modules {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// Name returns a short name for the module in dir, falling back to the directory name itself
+func (r Repository) Name(dir string) string {
+	for mod, d := range r.modules {
+		if d == dir {
+			return path.Base(mod)
+		}
+	}
+
+	return path.Base(dir)
+}
+
+// RelativeName turns the name of a file in a coverage profile into a path relative to the repository root, using the
+// longest matching module path
+func (r Repository) RelativeName(fileName string) (string, bool) {
+	// Profiles are written with forward slashes, but normalize in case one was put together on Windows
+	fileName = filepath.ToSlash(fileName)
+
+	best, bestDir := "", ""
+	for _, modules := range []map[string]string{r.modules, r.replaced} {
+		for mod, dir := range modules {
+			if strings.HasPrefix(fileName, mod+"/") && len(mod) > len(best) {
+				best, bestDir = mod, dir
+			}
+		}
+	}
+
+	if best == "" {
+		if r.gopath && strings.HasPrefix(fileName, r.root+"/") {
+			return strings.TrimPrefix(fileName, r.root+"/"), true
+		}
+		return fileName, false
+	}
+
+	return path.Join(bestDir, strings.TrimPrefix(fileName, best+"/")), true
+}
+
+// CheckProfiles makes sure every file in the coverage profiles belongs to one of the modules
+func (r Repository) CheckProfiles(profiles []*cover.Profile) error {
+	for _, profile := range profiles {
+		if _, ok := r.RelativeName(profile.FileName); !ok {
+			return fmt.Errorf("%s is not in any module under this directory", profile.FileName)
+		}
+	}
+
+	return nil
+}
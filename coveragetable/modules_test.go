@@ -0,0 +1,168 @@
+package coveragetable
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// twoModules is a repository with a module in app and one in lib, without a module at its root
+var twoModules = map[string]string{
+	"app/go.mod":      "module example.com/app\n\ngo 1.18\n",
+	"app/main.go":     "package main\n\nfunc main() {}\n",
+	"app/api/api.go":  "package api\n\nfunc Version() string {\n\treturn \"1\"\n}\n",
+	"lib/go.mod":      "module example.com/lib\n\ngo 1.18\n",
+	"lib/strs/str.go": "package strs\n\nfunc Upper(s string) string {\n\treturn s\n}\n",
+}
+
+func TestFindModules(t *testing.T) {
+	repo, err := FindModules(writeFiles(t, twoModules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := repo.TestDirs(), []string{"app", "lib"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestDirs() = %v, want %v", got, want)
+	}
+
+	names := map[string]string{
+		"example.com/app/api/api.go":  "app/api/api.go",
+		"example.com/lib/strs/str.go": "lib/strs/str.go",
+	}
+	for fileName, want := range names {
+		if got, ok := repo.RelativeName(fileName); !ok || got != want {
+			t.Errorf("RelativeName(%s) = %s, %v, want %s", fileName, got, ok, want)
+		}
+		if !repo.Tested(want) {
+			t.Errorf("Tested(%s) = false, want true", want)
+		}
+	}
+	if _, ok := repo.RelativeName("example.com/other/other.go"); ok {
+		t.Errorf("RelativeName() matched a file outside of both modules")
+	}
+}
+
+func TestFindModulesWorkspace(t *testing.T) {
+	files := map[string]string{"go.work": "go 1.18\n\nuse ./app\n"}
+	for name, content := range twoModules {
+		files[name] = content
+	}
+
+	repo, err := FindModules(writeFiles(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := repo.TestDirs(), []string{"app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestDirs() = %v, want %v", got, want)
+	}
+	if !repo.Tested("app/api/api.go") {
+		t.Errorf("Tested(app/api/api.go) = false, but go.work uses app")
+	}
+	if repo.Tested("lib/strs/str.go") {
+		t.Errorf("Tested(lib/strs/str.go) = true, but go.work doesn't use lib")
+	}
+}
+
+func TestRelativeNameNativePaths(t *testing.T) {
+	// filepath.Join uses backslashes on Windows, the way a profile put together there could name its files
+	root := filepath.Join(t.TempDir(), "my project")
+	repo := NewRepository(root, "example.com/m")
+
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{fileName: "example.com/m/my dir/a.go", want: "my dir/a.go"},
+		{fileName: filepath.Join("example.com", "m", "my dir", "a.go"), want: "my dir/a.go"},
+		{fileName: filepath.Join(root, "my dir", "b.go"), want: "my dir/b.go"},
+	}
+
+	for _, tt := range tests {
+		if got, ok := repo.RelativeName(tt.fileName); !ok || got != tt.want {
+			t.Errorf("RelativeName(%s) = %s, %v, want %s", tt.fileName, got, ok, tt.want)
+		}
+	}
+}
+
+func TestEnclosingModule(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"go.mod":                     "module example.com/m\n\ngo 1.18\n",
+		"internal/api/v1/handler.go": "package v1\n\nfunc Handle() int {\n\treturn 1\n}\n",
+	})
+	dir := filepath.Join(root, "internal", "api")
+
+	mod, err := enclosingModule(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "example.com/m/internal/api"; mod != want {
+		t.Errorf("enclosingModule() = %s, want %s", mod, want)
+	}
+
+	// Run from the nested directory, names are relative to it rather than to the module
+	repo, err := FindModules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := repo.RelativeName("example.com/m/internal/api/v1/handler.go"); !ok || got != "v1/handler.go" {
+		t.Errorf("RelativeName() = %s, %v, want v1/handler.go", got, ok)
+	}
+	if _, ok := repo.RelativeName("example.com/m/internal/other/other.go"); ok {
+		t.Errorf("RelativeName() matched a file outside of the nested directory")
+	}
+}
+
+func TestFindModulesReplace(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"app/go.mod": `module example.com/app
+
+go 1.18
+
+require (
+	example.com/lib v1.0.0
+	example.com/util v1.0.0
+)
+
+replace example.com/lib => ../forks/lib
+
+replace (
+	example.com/util v1.0.0 => ./util
+	example.com/remote => example.com/fork v1.2.3
+)
+`,
+		"app/app.go":           "package app\n\nfunc Run() {\n\tprintln(\"run\")\n}\n",
+		"app/util/go.mod":      "module example.com/util\n\ngo 1.18\n",
+		"app/util/util.go":     "package util\n\nfunc Help() {\n\tprintln(\"help\")\n}\n",
+		"forks/lib/go.mod":     "module example.com/forked\n\ngo 1.18\n",
+		"forks/lib/lib.go":     "package lib\n\nfunc Lib() {\n\tprintln(\"lib\")\n}\n",
+		"forks/lib/sub/sub.go": "package sub\n\nfunc Sub() {\n\tprintln(\"sub\")\n}\n",
+	})
+
+	repo, err := FindModules(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Profiles can name the files of a replaced module by the path it's replacing, not just by the one in its go.mod
+	names := map[string]string{
+		"example.com/app/app.go":       "app/app.go",
+		"example.com/util/util.go":     "app/util/util.go",
+		"example.com/lib/lib.go":       "forks/lib/lib.go",
+		"example.com/lib/sub/sub.go":   "forks/lib/sub/sub.go",
+		"example.com/forked/lib.go":    "forks/lib/lib.go",
+		"example.com/remote/remote.go": "",
+	}
+	for fileName, want := range names {
+		got, ok := repo.RelativeName(fileName)
+		if want == "" {
+			if ok {
+				t.Errorf("RelativeName(%s) = %s, but it's replaced by a module rather than a directory", fileName, got)
+			}
+			continue
+		}
+		if !ok || got != want {
+			t.Errorf("RelativeName(%s) = %s, %v, want %s", fileName, got, ok, want)
+		}
+	}
+}
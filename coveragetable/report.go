@@ -0,0 +1,356 @@
+// Package coveragetable finds the go files in a directory, matches them up with coverage profiles, and renders the
+// coverage of every file as a table or one of the other formats coverage-table supports.
+package coveragetable
+
+import (
+	"errors"
+	"golang.org/x/tools/cover"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Table is the coverage of every file counting towards the total, sorted by name
+type Table struct {
+	Rows  []Row
+	Total Coverage
+	// Baseline is the coverage of a previous run to compare against, if any
+	Baseline *Baseline
+	// Header is rendered above the rows when set
+	Header *Header
+	// Hidden is the number of rows left out by Top or Grep, which still count towards the total unless Grep was told
+	// otherwise
+	Hidden int
+	// blocks holds the profile blocks of every file in the coverage profile, by name
+	blocks map[string][]cover.ProfileBlock
+	// weakLimit is the limit set by CountWeak, for counting the rows built from blocks later on the same way
+	weakLimit int
+}
+
+// Row is a single named line in the report
+type Row struct {
+	Name string
+	Coverage
+	// Empty is set for rows without any statements to cover, which are shown with a dash
+	Empty bool
+}
+
+// AbsNames returns the report with every row named by its absolute path below root, instead of the path relative to
+// it. Names in the baseline and of the profile blocks are changed to match.
+func AbsNames(root string, r Table) Table {
+	return renameRows(r, func(name string) string {
+		return filepath.Join(root, filepath.FromSlash(name))
+	})
+}
+
+// RelativeNames returns the report with every row named by its path relative to base, instead of the path relative to
+// root. As with AbsNames, names in the baseline and of the profile blocks are changed to match.
+func RelativeNames(root, base string, r Table) (Table, error) {
+	rel, err := filepath.Rel(base, root)
+	if err != nil {
+		return Table{}, err
+	}
+
+	return renameRows(r, func(name string) string {
+		return path.Join(filepath.ToSlash(rel), name)
+	}), nil
+}
+
+// renameRows returns the report with every relative name passed through rename, leaving the original untouched
+func renameRows(r Table, rename func(string) string) Table {
+	names := func(name string) string {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return rename(name)
+	}
+
+	rows := make([]Row, len(r.Rows))
+	for i, row := range r.Rows {
+		row.Name = names(row.Name)
+		rows[i] = row
+	}
+	r.Rows = rows
+
+	blocks := make(map[string][]cover.ProfileBlock, len(r.blocks))
+	for name, b := range r.blocks {
+		blocks[names(name)] = b
+	}
+	r.blocks = blocks
+
+	if r.Baseline != nil {
+		b := &Baseline{Files: make(map[string]float64, len(r.Baseline.Files)), Total: r.Baseline.Total}
+		for name, cov := range r.Baseline.Files {
+			b.Files[names(name)] = cov
+		}
+		r.Baseline = b
+	}
+
+	return r
+}
+
+// BuildTable counts the covered statements of every file found, and of any other file in the profiles. Only files
+// kept by filter are part of the report.
+func BuildTable(repo Repository, found GoFiles, profiles []*cover.Profile, filter FileFilter) (Table, error) {
+	files := found.Files
+
+	// Count covered statements for files in coverage report
+	r := Table{blocks: make(map[string][]cover.ProfileBlock)}
+	matched := 0
+	for _, profile := range profiles {
+		name, _ := repo.RelativeName(profile.FileName)
+		cov := countStatements(profile)
+
+		// Files we chose not to show have nothing to update
+		if found.Skipped[name] {
+			debugf("ignoring %s in coverage profile: skipped while looking for go files", name)
+			matched++
+			continue
+		}
+
+		// Generated or vendored files can legitimately show up in a profile without being found by the walk, so they
+		// get a row of their own
+		if _, ok := files[name]; ok {
+			matched++
+		} else {
+			warnf("file in coverage profile was not found in path: %s", name)
+		}
+
+		files[name] = cov
+		r.blocks[name] = profile.Blocks
+	}
+
+	// Not matching anything at all most likely means the module path is wrong
+	if len(profiles) > 0 && matched == 0 {
+		return Table{}, errors.New("none of the files in the coverage profile were found in path")
+	}
+
+	for n, p := range files {
+		if !filter.Keep(n) {
+			debugf("leaving out %s: excluded by -include or -exclude", n)
+			continue
+		}
+
+		r.Rows = append(r.Rows, Row{Name: n, Coverage: p, Empty: found.Empty[n]})
+		r.Total.Add(p)
+	}
+
+	sortRows(r.Rows)
+
+	return r, nil
+}
+
+// Compute works out the coverage of a set of coverage profiles, using the file names in the profiles as they are.
+// Files that can be covered but have no profile, like files without tests, can be passed in files with their number of
+// statements, and are counted as uncovered unless one of the profiles covers them. When several profiles are for the
+// same file the last one counts. Unlike BuildTable nothing is filtered, and nothing is read from disk or logged.
+func Compute(profiles []*cover.Profile, files map[string]float64) Report {
+	counts := make(map[string]Coverage, len(files)+len(profiles))
+	for name, statements := range files {
+		counts[name] = Coverage{Total: int64(statements)}
+	}
+	for _, profile := range profiles {
+		counts[profile.FileName] = countStatements(profile)
+	}
+
+	var t Table
+	for name, c := range counts {
+		t.Rows = append(t.Rows, Row{Name: name, Coverage: c})
+		t.Total.Add(c)
+	}
+
+	sortRows(t.Rows)
+
+	return t.Report()
+}
+
+// CountWeak returns the report with the statements that ran at most limit times counted as weakly covered, for every
+// file in the coverage profile. Go counts whole blocks rather than single statements, so every statement of a block is
+// weak or not together, and with the set cover mode every block that ran did so once as far as the profile can tell.
+func CountWeak(r Table, limit int) Table {
+	counted := r
+	counted.weakLimit = limit
+	counted.Total = Coverage{}
+	counted.Rows = make([]Row, len(r.Rows))
+	for i, row := range r.Rows {
+		if blocks, ok := r.blocks[row.Name]; ok {
+			row.Coverage = Coverage{}
+			for _, block := range blocks {
+				row.addBlock(block, limit)
+			}
+		}
+		counted.Rows[i] = row
+		counted.Total.Add(row.Coverage)
+	}
+
+	return counted
+}
+
+// GroupByPackage combines the rows of a report into a single row per package directory
+func GroupByPackage(r Table) Table {
+	return groupRows(r, path.Dir)
+}
+
+// GroupByDepth combines the rows of a report into a single row per directory at most depth levels deep, so with a
+// depth of 1 'internal/api/server.go' is counted towards 'internal'. Files less deep than that are grouped by their
+// own directory.
+func GroupByDepth(r Table, depth int) Table {
+	return groupRows(r, func(name string) string {
+		dir := path.Dir(name)
+		if dir == "." {
+			return dir
+		}
+		if parts := strings.Split(dir, "/"); len(parts) > depth {
+			return path.Join(parts[:depth]...)
+		}
+		return dir
+	})
+}
+
+// groupRows combines the rows of a report into a single row per group, as named by group
+func groupRows(r Table, group func(name string) string) Table {
+	groups := make(map[string]Coverage)
+	// A group is only empty when all of its files are
+	empty := make(map[string]bool)
+	for _, row := range r.Rows {
+		name := group(row.Name)
+		c := groups[name]
+		c.Add(row.Coverage)
+		groups[name] = c
+
+		if e, ok := empty[name]; !ok || e {
+			empty[name] = row.Empty
+		}
+	}
+
+	grouped := Table{Total: r.Total, Baseline: r.Baseline, blocks: r.blocks, weakLimit: r.weakLimit}
+	for name, c := range groups {
+		grouped.Rows = append(grouped.Rows, Row{Name: name, Coverage: c, Empty: empty[name]})
+	}
+
+	sortRows(grouped.Rows)
+
+	return grouped
+}
+
+// SortRowsByCoverage sorts rows from least to most covered, falling back to names for rows with the same coverage
+func SortRowsByCoverage(rows []Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		if pi, pj := rows[i].Percent(), rows[j].Percent(); pi != pj {
+			return pi < pj
+		}
+		return rows[i].Name < rows[j].Name
+	})
+}
+
+// Top returns the report with only the n least covered rows, keeping them in their current order. Rows without any
+// statements are left out, as there's nothing to test in them. The total covers every row, hidden or not.
+func Top(r Table, n int) Table {
+	worst := make([]Row, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		if !row.Empty {
+			worst = append(worst, row)
+		}
+	}
+	SortRowsByCoverage(worst)
+	if len(worst) > n {
+		worst = worst[:n]
+	}
+
+	keep := make(map[string]bool, len(worst))
+	for _, row := range worst {
+		keep[row.Name] = true
+	}
+	rows := make([]Row, 0, len(worst))
+	for _, row := range r.Rows {
+		if keep[row.Name] {
+			rows = append(rows, row)
+		}
+	}
+
+	r.Hidden += len(r.Rows) - len(rows)
+	r.Rows = rows
+	return r
+}
+
+// Grep returns the report with only the rows whose name contains substr, ignoring case. The total still covers every
+// row, unless scopeTotal is set to only cover the rows that are left.
+func Grep(r Table, substr string, scopeTotal bool) Table {
+	substr = strings.ToLower(substr)
+
+	rows := make([]Row, 0, len(r.Rows))
+	var total Coverage
+	for _, row := range r.Rows {
+		if strings.Contains(strings.ToLower(row.Name), substr) {
+			rows = append(rows, row)
+			total.Add(row.Coverage)
+		}
+	}
+
+	if scopeTotal {
+		r.Total = total
+	} else {
+		r.Hidden += len(r.Rows) - len(rows)
+	}
+	r.Rows = rows
+	return r
+}
+
+// sortRows sorts rows so we can go through them in lexicographical order
+func sortRows(rows []Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Name < rows[j].Name
+	})
+}
+
+// Coverage holds the number of covered and total statements for a file, or for a set of files
+type Coverage struct {
+	Covered int64
+	Total   int64
+	// Weak is the number of covered statements that ran no more than the limit passed to CountWeak, and always zero
+	// without it
+	Weak int64
+}
+
+// Add adds the statements of o to c
+func (c *Coverage) Add(o Coverage) {
+	c.Covered += o.Covered
+	c.Total += o.Total
+	c.Weak += o.Weak
+}
+
+// addBlock adds the statements of a profile block to c, counting them as weak when the block ran at most weakLimit
+// times
+func (c *Coverage) addBlock(block cover.ProfileBlock, weakLimit int) {
+	c.Total += int64(block.NumStmt)
+	// Only statement coverage matters, so any block that ran is covered regardless of cover mode
+	if block.Count > 0 {
+		c.Covered += int64(block.NumStmt)
+		if block.Count <= weakLimit {
+			c.Weak += int64(block.NumStmt)
+		}
+	}
+}
+
+// Percent returns the percentage of statements that are covered
+func (c Coverage) Percent() float64 {
+	// Without any statements there's nothing to divide by, which would otherwise be NaN
+	if c.Total == 0 {
+		return 0
+	}
+
+	// Multiplying first keeps whole percentages exact, 29 of 100 statements would otherwise come out just below 29%
+	// and end up in the wrong color band
+	return float64(c.Covered) * 100 / float64(c.Total)
+}
+
+func countStatements(p *cover.Profile) Coverage {
+	var c Coverage
+
+	for _, block := range p.Blocks {
+		c.addBlock(block, 0)
+	}
+
+	return c
+}
@@ -0,0 +1,220 @@
+package coveragetable
+
+import (
+	"bytes"
+	"encoding/json"
+	"golang.org/x/tools/cover"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// profile returns a profile for name with a block per entry of counts, each holding statements statements
+func profile(name string, statements int, counts ...int) *cover.Profile {
+	p := &cover.Profile{FileName: name, Mode: "count"}
+	for i, count := range counts {
+		p.Blocks = append(p.Blocks, cover.ProfileBlock{
+			StartLine: i + 1,
+			EndLine:   i + 1,
+			NumStmt:   statements,
+			Count:     count,
+		})
+	}
+
+	return p
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []*cover.Profile
+		files    map[string]float64
+		want     Report
+	}{
+		{
+			name: "nothing",
+			want: Report{Files: []FileCoverage{}},
+		},
+		{
+			name:     "single profile",
+			profiles: []*cover.Profile{profile("a.go", 2, 1, 0, 3, 0)},
+			want: Report{
+				Files:             []FileCoverage{{Name: "a.go", Coverage: 50, CoveredStatements: 4, TotalStatements: 8}},
+				Total:             50,
+				CoveredStatements: 4,
+				TotalStatements:   8,
+			},
+		},
+		{
+			name:     "sorted by name",
+			profiles: []*cover.Profile{profile("b.go", 1, 1), profile("a.go", 1, 0)},
+			want: Report{
+				Files: []FileCoverage{
+					{Name: "a.go", Coverage: 0, CoveredStatements: 0, TotalStatements: 1},
+					{Name: "b.go", Coverage: 100, CoveredStatements: 1, TotalStatements: 1},
+				},
+				Total:             50,
+				CoveredStatements: 1,
+				TotalStatements:   2,
+			},
+		},
+		{
+			name:     "files without a profile are uncovered",
+			profiles: []*cover.Profile{profile("a.go", 1, 1)},
+			files:    map[string]float64{"untested.go": 3},
+			want: Report{
+				Files: []FileCoverage{
+					{Name: "a.go", Coverage: 100, CoveredStatements: 1, TotalStatements: 1},
+					{Name: "untested.go", Coverage: 0, CoveredStatements: 0, TotalStatements: 3},
+				},
+				Total:             25,
+				CoveredStatements: 1,
+				TotalStatements:   4,
+			},
+		},
+		{
+			name:     "profiles win over files",
+			profiles: []*cover.Profile{profile("a.go", 1, 1, 1)},
+			files:    map[string]float64{"a.go": 10},
+			want: Report{
+				Files:             []FileCoverage{{Name: "a.go", Coverage: 100, CoveredStatements: 2, TotalStatements: 2}},
+				Total:             100,
+				CoveredStatements: 2,
+				TotalStatements:   2,
+			},
+		},
+		{
+			name:     "last profile of a file counts",
+			profiles: []*cover.Profile{profile("a.go", 1, 0), profile("a.go", 1, 1)},
+			want: Report{
+				Files:             []FileCoverage{{Name: "a.go", Coverage: 100, CoveredStatements: 1, TotalStatements: 1}},
+				Total:             100,
+				CoveredStatements: 1,
+				TotalStatements:   1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(tt.profiles, tt.files)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute() = %+v, want %+v", got, tt.want)
+			}
+			if again := Compute(tt.profiles, tt.files); !reflect.DeepEqual(again, got) {
+				t.Errorf("Compute() changed between calls: %+v, then %+v", got, again)
+			}
+		})
+	}
+}
+
+func TestComputeLeavesInputsAlone(t *testing.T) {
+	profiles := []*cover.Profile{profile("b.go", 1, 1), profile("a.go", 1, 0)}
+	files := map[string]float64{"c.go": 2}
+
+	Compute(profiles, files)
+
+	if profiles[0].FileName != "b.go" || profiles[1].FileName != "a.go" {
+		t.Errorf("Compute() reordered the profiles")
+	}
+	if !reflect.DeepEqual(files, map[string]float64{"c.go": 2}) {
+		t.Errorf("Compute() changed files to %v", files)
+	}
+}
+
+func TestReportMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+		want   string
+	}{
+		{
+			name:   "empty",
+			report: Report{},
+			want:   `{"files":[],"total":0,"coveredStatements":0,"totalStatements":0}`,
+		},
+		{
+			name:   "files",
+			report: Compute([]*cover.Profile{profile("a.go", 1, 1, 0)}, nil),
+			want: `{"files":[{"name":"a.go","coverage":50,"coveredStatements":1,"totalStatements":2}],` +
+				`"total":50,"coveredStatements":1,"totalStatements":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTableWeightsTotalByStatements(t *testing.T) {
+	// A small file that's fully covered and a big one that barely is
+	small := profile("example.com/m/small.go", 5, 1)
+	big := profile("example.com/m/big.go", 50, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	found := GoFiles{Files: map[string]Coverage{"small.go": {}, "big.go": {}}}
+
+	r, err := BuildTable(NewRepository("/m", "example.com/m"), found, []*cover.Profile{small, big}, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	naive := 0.0
+	for _, row := range r.Rows {
+		naive += row.Percent() / float64(len(r.Rows))
+	}
+	want := float64(5+50) * 100 / float64(5+500)
+	if got := r.Total.Percent(); got != want {
+		t.Errorf("total = %v, want %v", got, want)
+	}
+	if r.Total.Percent() == naive {
+		t.Errorf("total = %v, the same as the average of the files", naive)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, Options{Precision: DefaultPrecision}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "10.89") {
+		t.Errorf("footer doesn't show the weighted total of 10.89:\n%s", buf.String())
+	}
+}
+
+func TestCountStatementsIgnoresExecutionCount(t *testing.T) {
+	// A hot block that ran far more often than it has statements
+	c := countStatements(profile("a.go", 3, 500))
+
+	if c.Covered != 3 {
+		t.Errorf("covered = %d, want 3", c.Covered)
+	}
+	if c.Percent() > 100 {
+		t.Errorf("percent = %v, want at most 100", c.Percent())
+	}
+}
+
+func TestEverythingExcluded(t *testing.T) {
+	found := GoFiles{Files: map[string]Coverage{"mocks/a.go": {}, "mocks/b.go": {}}}
+	profiles := []*cover.Profile{profile("example.com/m/mocks/a.go", 2, 1)}
+	filter := FileFilter{Excludes: []string{"**/mocks/**"}}
+
+	r, err := BuildTable(NewRepository("/m", "example.com/m"), found, profiles, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"table", "csv", "markdown"} {
+		var buf bytes.Buffer
+		if err := r.Render(&buf, Options{Format: format, Precision: DefaultPrecision}); err != nil {
+			t.Fatal(err)
+		}
+		if out := buf.String(); strings.Contains(out, "NaN") || !strings.Contains(out, "0.00") {
+			t.Errorf("%s doesn't render a total of 0.00:\n%s", format, out)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package coveragetable
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// sarifRule is the id of the rule every result in the sarif format is reported under
+const sarifRule = "low-coverage"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string          `json:"name"`
+		InformationURI string          `json:"informationUri"`
+		Rules          []sarifRuleDesc `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRuleDesc struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		// Code scanning wants a region, even though the whole file is meant
+		Region struct {
+			StartLine int `json:"startLine"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// printSARIF renders every file below the file threshold as a result of a SARIF 2.1.0 log, for GitHub code scanning
+// and other tools that read static analysis results
+func printSARIF(w io.Writer, r Table, opts Options) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "coverage-table"
+	run.Tool.Driver.InformationURI = "https://github.com/tehbilly/coverage-table"
+	run.Tool.Driver.Rules = []sarifRuleDesc{{
+		ID:               sarifRule,
+		ShortDescription: sarifMessage{Text: "File coverage is below the threshold"},
+	}}
+
+	for _, row := range r.Rows {
+		cov := row.Percent()
+		if row.Empty || cov >= opts.FileThreshold {
+			continue
+		}
+
+		result := sarifResult{
+			RuleID: sarifRule,
+			Level:  "warning",
+			Message: sarifMessage{
+				Text: fmt.Sprintf("Coverage is %s%%, below the file threshold of %s%%", opts.Percent(cov), opts.Percent(opts.FileThreshold)),
+			},
+			Locations: make([]sarifLocation, 1),
+		}
+		result.Locations[0].PhysicalLocation.ArtifactLocation.URI = row.Name
+		result.Locations[0].PhysicalLocation.Region.StartLine = 1
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,80 @@
+package coveragetable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Line coverage marks, for when there's no color to tell covered lines apart
+const (
+	lineCovered   = "+"
+	lineUncovered = "-"
+)
+
+// Escape codes for the gutter of covered and uncovered lines
+const (
+	gutterCovered   = "\033[42m \033[0m"
+	gutterUncovered = "\033[41m \033[0m"
+)
+
+// PrintSource writes the source of the file with the given name, relative to root, with every line marked by whether
+// it was covered. A line is uncovered when any statement on it never ran, so partly covered lines stand out as well.
+// Lines without statements aren't marked.
+func PrintSource(w io.Writer, root, name string, r Table, opts Options) error {
+	found := false
+	for _, row := range r.Rows {
+		if row.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s is not part of the report", name)
+	}
+
+	// counts holds, per line, whether it was covered: 1 when every block on it ran, -1 when any didn't
+	counts := make(map[int]int)
+	for _, b := range r.blocks[name] {
+		for line := b.StartLine; line <= b.EndLine; line++ {
+			switch {
+			case b.Count == 0:
+				counts[line] = -1
+			case counts[line] == 0:
+				counts[line] = 1
+			}
+		}
+	}
+
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(f)
+	// Generated files can have very long lines
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		mark := " "
+		switch {
+		case counts[line] > 0 && opts.Color:
+			mark = gutterCovered
+		case counts[line] > 0:
+			mark = lineCovered
+		case counts[line] < 0 && opts.Color:
+			mark = gutterUncovered
+		case counts[line] < 0:
+			mark = lineUncovered
+		}
+		fmt.Fprintf(bw, "%5d %s %s\n", line, mark, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,101 @@
+package coveragetable
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/tools/cover"
+	"strings"
+	"testing"
+)
+
+// sourceLines is the fixture PrintSource shows, one entry per line
+var sourceLines = []string{
+	"package src",
+	"",
+	"func Covered() int {",
+	"\treturn 1",
+	"}",
+	"",
+	"func Partly(b bool) int {",
+	"\tif b {",
+	"\t\treturn 2",
+	"\t}",
+	"\treturn 3",
+	"}",
+}
+
+// sourceTable returns a table of the fixture, where Covered ran and Partly never took its branch
+func sourceTable(t *testing.T) (string, Table) {
+	t.Helper()
+
+	root := writeFiles(t, map[string]string{"src/src.go": strings.Join(sourceLines, "\n") + "\n"})
+	found := GoFiles{Files: map[string]Coverage{"src/src.go": {}}}
+	profiles := []*cover.Profile{{
+		FileName: "example.com/m/src/src.go",
+		Mode:     "set",
+		Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 20, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1},
+			{StartLine: 7, StartCol: 25, EndLine: 8, EndCol: 7, NumStmt: 1, Count: 1},
+			{StartLine: 8, StartCol: 7, EndLine: 10, EndCol: 3, NumStmt: 1, Count: 0},
+			{StartLine: 10, StartCol: 3, EndLine: 12, EndCol: 2, NumStmt: 1, Count: 1},
+		},
+	}}
+
+	r, err := BuildTable(NewRepository(root, "example.com/m"), found, profiles, FileFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root, r
+}
+
+func TestPrintSource(t *testing.T) {
+	// Lines 8 and 10 are partly covered, which counts as uncovered
+	marks := []int{0, 0, 1, 1, 1, 0, 1, -1, -1, -1, 1, 1}
+
+	tests := []struct {
+		name               string
+		color              bool
+		covered, uncovered string
+	}{
+		{name: "plain", covered: "+", uncovered: "-"},
+		{name: "color", color: true, covered: "\033[42m \033[0m", uncovered: "\033[41m \033[0m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want strings.Builder
+			for i, line := range sourceLines {
+				mark := " "
+				switch marks[i] {
+				case 1:
+					mark = tt.covered
+				case -1:
+					mark = tt.uncovered
+				}
+				fmt.Fprintf(&want, "%5d %s %s\n", i+1, mark, line)
+			}
+
+			root, r := sourceTable(t)
+			var buf bytes.Buffer
+			if err := PrintSource(&buf, root, "src/src.go", r, Options{Color: tt.color}); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != want.String() {
+				t.Errorf("PrintSource() =\n%s\nwant:\n%s", got, want.String())
+			}
+		})
+	}
+}
+
+func TestPrintSourceNotInReport(t *testing.T) {
+	root, r := sourceTable(t)
+
+	var buf bytes.Buffer
+	if err := PrintSource(&buf, root, "src/other.go", r, Options{}); err == nil {
+		t.Errorf("PrintSource() of a file that's not in the report returned no error")
+	}
+	if buf.Len() > 0 {
+		t.Errorf("PrintSource() wrote %q for a file that's not in the report", buf.String())
+	}
+}
@@ -1,9 +1,18 @@
 module github.com/tehbilly/coverage-table
 
-go 1.15
+go 1.18
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/olekukonko/tablewriter v0.0.4
 	golang.org/x/mod v0.3.0
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 	golang.org/x/tools v0.0.0-20201117152513-9036a0f9af11
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/mattn/go-runewidth v0.0.7 // indirect
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
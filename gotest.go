@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"golang.org/x/tools/cover"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runTests runs 'go test' with coverage enabled in dir, returning the parsed coverage profiles. With gopath set, dir is
+// a package in GOPATH rather than a module, so modules are turned off for the tests.
+func runTests(dir, name, pattern string, gopath bool) (profiles []*cover.Profile, err error) {
+	// We want to store coverage results in a temporary file so we're not cluttering things up
+	f, err := os.CreateTemp(*tmpDir, fmt.Sprintf("%s-*.out", name))
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file for coverage data: %w", err)
+	}
+	f.Close()
+	defer func() {
+		// A leftover file is worth knowing about, but not at the expense of an error that's already being returned
+		if rerr := os.Remove(f.Name()); rerr != nil && err == nil {
+			err = fmt.Errorf("removing temporary file for coverage data: %w", rerr)
+		}
+	}()
+
+	cmd := exec.Command(*goBinary, goTestArgs(f.Name(), pattern)...)
+	cmd.Dir = dir
+	// Passed on as is, so GOFLAGS, GO111MODULE, and the like apply to the tests just as they would on the command line
+	cmd.Env = os.Environ()
+	if gopath {
+		cmd.Env = append(cmd.Env, "GO111MODULE=off")
+	}
+	switch *cgo {
+	case "on":
+		cmd.Env = append(cmd.Env, "CGO_ENABLED=1")
+	case "off":
+		cmd.Env = append(cmd.Env, "CGO_ENABLED=0")
+	}
+	// 'go test' can take a while on big repositories, without showing anything until it's done
+	stop := startSpinner(fmt.Sprintf("Running 'go test' in %s", dir))
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	stop()
+	timef(start, "'go test' in %s", dir)
+	if err != nil {
+		// Show what went wrong, since the exit status alone doesn't say much
+		os.Stderr.Write(out)
+		return nil, fmt.Errorf("running 'go test' in %s: %w", dir, err)
+	}
+
+	start = time.Now()
+	profiles, err = cover.ParseProfiles(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("parsing coverage profile: %w", err)
+	}
+	timef(start, "parsing the coverage profile of %s", dir)
+
+	return profiles, nil
+}
+
+// goTestArgs returns the arguments for running 'go test' on the packages matching pattern with coverage written to
+// profile. Flags that have a flag of their own are added before any -test-args, with the package pattern last.
+func goTestArgs(profile, pattern string) []string {
+	args := []string{"test", "-coverprofile", profile}
+	mode := *coverMode
+	// The race detector needs atomic counters, which is what 'go test -race' would pick itself, but saying so keeps
+	// the mode in plain sight
+	if mode == "" && *race {
+		mode = "atomic"
+	}
+	// Telling how often a block ran takes counters rather than the default of just setting a flag
+	if mode == "" && *partial {
+		mode = "count"
+	}
+	if mode != "" {
+		args = append(args, "-covermode", mode)
+	}
+	if *race {
+		args = append(args, "-race")
+	}
+	if *modMode != "" {
+		args = append(args, "-mod", *modMode)
+	}
+	if *tags != "" {
+		args = append(args, "-tags", *tags)
+	}
+	if *short {
+		args = append(args, "-short")
+	}
+	if *parallel > 0 {
+		args = append(args, "-p", strconv.Itoa(*parallel))
+	}
+	if *count > 0 {
+		args = append(args, "-count", strconv.Itoa(*count))
+	}
+	if *timeout != "" {
+		args = append(args, "-timeout", *timeout)
+	}
+	if *shuffle != "" {
+		args = append(args, "-shuffle", *shuffle)
+	}
+	if *failFast {
+		args = append(args, "-failfast")
+	}
+	args = append(args, strings.Fields(*testArgs)...)
+
+	return append(args, pattern)
+}
+
+// checkShuffle makes sure value is something 'go test -shuffle' accepts
+func checkShuffle(value string) error {
+	switch value {
+	case "", "on", "off":
+		return nil
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fmt.Errorf("Invalid -shuffle %q, expected on, off, or an integer seed", value)
+	}
+
+	return nil
+}
+
+// cgoEnabled reports whether 'go test' builds with cgo, so the walk picks up the same files it builds. For -cgo=auto the
+// go command is asked, as it also turns cgo off by itself when there's no C compiler.
+func cgoEnabled() bool {
+	switch *cgo {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+
+	out, err := exec.Command(*goBinary, "env", "CGO_ENABLED").Output()
+	if err != nil {
+		debugf("unable to ask go whether cgo is enabled, assuming %t: %s", build.Default.CgoEnabled, err)
+		return build.Default.CgoEnabled
+	}
+
+	return strings.TrimSpace(string(out)) == "1"
+}
+
+// buildTags splits the -tags flag into separate tags, accepting spaces as well as commas like 'go build' does
+func buildTags() []string {
+	return strings.FieldsFunc(*tags, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// setString sets the flag p points to for the rest of the test
+func setString(t *testing.T, p *string, value string) {
+	old := *p
+	*p = value
+	t.Cleanup(func() { *p = old })
+}
+
+func TestGoTestArgsModMode(t *testing.T) {
+	for _, mode := range []string{"readonly", "vendor", "mod"} {
+		t.Run(mode, func(t *testing.T) {
+			setString(t, modMode, mode)
+
+			want := []string{"test", "-coverprofile", "c.out", "-mod", mode, "./..."}
+			if got := goTestArgs("c.out", "./..."); !reflect.DeepEqual(got, want) {
+				t.Errorf("goTestArgs() = %q, want %q", got, want)
+			}
+		})
+	}
+
+	want := []string{"test", "-coverprofile", "c.out", "./..."}
+	if got := goTestArgs("c.out", "./..."); !reflect.DeepEqual(got, want) {
+		t.Errorf("goTestArgs() = %q without -mod, want %q", got, want)
+	}
+}
+
+func TestGoTestArgsRace(t *testing.T) {
+	setBool(t, race, true)
+
+	want := []string{"test", "-coverprofile", "c.out", "-covermode", "atomic", "-race", "./..."}
+	if got := goTestArgs("c.out", "./..."); !reflect.DeepEqual(got, want) {
+		t.Errorf("goTestArgs() = %q, want %q", got, want)
+	}
+
+	// Asking for atomic counters outright gives the same arguments
+	setString(t, coverMode, "atomic")
+	if got := goTestArgs("c.out", "./..."); !reflect.DeepEqual(got, want) {
+		t.Errorf("goTestArgs() = %q with -covermode=atomic, want %q", got, want)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{value: "on", valid: true},
+		{value: "off", valid: true},
+		{value: "1234", valid: true},
+		{value: "-5", valid: true},
+		{value: "yes", valid: false},
+		{value: "1.5", valid: false},
+		{value: "99999999999999999999", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if err := checkShuffle(tt.value); (err == nil) != tt.valid {
+				t.Fatalf("checkShuffle(%q) = %v, want valid = %v", tt.value, err, tt.valid)
+			}
+			if !tt.valid {
+				return
+			}
+
+			setString(t, shuffle, tt.value)
+			want := []string{"test", "-coverprofile", "c.out", "-shuffle", tt.value, "./..."}
+			if got := goTestArgs("c.out", "./..."); !reflect.DeepEqual(got, want) {
+				t.Errorf("goTestArgs() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRunTestsRemovesProfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake go binary is a shell script")
+	}
+
+	// A fake go binary writes a profile wherever it's told to, and says where that was
+	dir := t.TempDir()
+	record := filepath.Join(dir, "profile-name")
+	script := "#!/bin/sh\n" +
+		"while [ \"$1\" != -coverprofile ]; do shift; done\n" +
+		"echo \"$2\" > " + record + "\n" +
+		"printf 'mode: set\\nexample.com/m/a.go:3.24,5.2 1 1\\n' > \"$2\"\n"
+	binary := filepath.Join(dir, "go")
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	setString(t, goBinary, binary)
+	setString(t, tmpDir, t.TempDir())
+
+	profiles, err := runTests(dir, "app", "./...", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[0].FileName != "example.com/m/a.go" {
+		t.Errorf("profiles = %+v, want the one for example.com/m/a.go", profiles)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := strings.TrimSpace(string(data))
+	if ok, _ := filepath.Match(filepath.Join(*tmpDir, "app-*.out"), name); !ok {
+		t.Errorf("profile written to %s, want app-*.out in %s", name, *tmpDir)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("profile %s is still there after the run: %v", name, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tehbilly/coverage-table/coveragetable"
+	"os"
+	"sort"
+	"time"
+)
+
+// logLevels maps the names accepted by -log-level to their level
+var logLevels = map[string]coveragetable.Level{
+	"debug": coveragetable.LevelDebug,
+	"info":  coveragetable.LevelInfo,
+	"warn":  coveragetable.LevelWarn,
+	"error": coveragetable.LevelError,
+}
+
+func logLevelNames() []string {
+	names := make([]string, 0, len(logLevels))
+	for name := range logLevels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return logLevels[names[i]] < logLevels[names[j]]
+	})
+
+	return names
+}
+
+// logPrefixes start every line written at a level, errors already speak for themselves
+var logPrefixes = map[coveragetable.Level]string{
+	coveragetable.LevelDebug: "Debug: ",
+	coveragetable.LevelInfo:  "Note: ",
+	coveragetable.LevelWarn:  "Warning: ",
+	coveragetable.LevelError: "",
+}
+
+// minLogLevel is the least important level that's still written to stderr
+var minLogLevel = coveragetable.LevelWarn
+
+// logf writes a diagnostic to stderr, prefixed by its level. It's handed to coveragetable as well, so its
+// diagnostics end up in the same place.
+func logf(level coveragetable.Level, format string, args ...interface{}) {
+	if level < minLogLevel {
+		return
+	}
+	fmt.Fprintf(os.Stderr, logPrefixes[level]+format+"\n", args...)
+}
+
+// debugf explains decisions that are only interesting when something doesn't turn out as expected
+func debugf(format string, args ...interface{}) {
+	logf(coveragetable.LevelDebug, format, args...)
+}
+
+// infof reports progress, like which module is being tested
+func infof(format string, args ...interface{}) {
+	logf(coveragetable.LevelInfo, format, args...)
+}
+
+// warnf reports something that's probably a mistake, but doesn't keep the report from being generated
+func warnf(format string, args ...interface{}) {
+	logf(coveragetable.LevelWarn, format, args...)
+}
+
+// errorf reports why coverage-table failed
+func errorf(format string, args ...interface{}) {
+	logf(coveragetable.LevelError, format, args...)
+}
+
+// timef writes how long a step that started at start took to stderr, when asked for with -timing. Timings aren't
+// diagnostics, so they're written whatever the log level.
+func timef(start time.Time, format string, args ...interface{}) {
+	if !*timing {
+		return
+	}
+	// Milliseconds are plenty for 'go test', but parsing a small profile takes less than that
+	took := time.Since(start)
+	if took >= time.Millisecond {
+		took = took.Round(time.Millisecond)
+	} else {
+		took = took.Round(time.Microsecond)
+	}
+	fmt.Fprintf(os.Stderr, "Timing: %s took %s\n", fmt.Sprintf(format, args...), took)
+}
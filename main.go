@@ -1,228 +1,971 @@
 package main
 
 import (
-	"bufio"
 	"errors"
+	"flag"
 	"fmt"
-	"github.com/olekukonko/tablewriter"
-	"golang.org/x/mod/modfile"
+	"github.com/tehbilly/coverage-table/coveragetable"
+	"go/build"
+	"golang.org/x/mod/module"
+	"golang.org/x/term"
 	"golang.org/x/tools/cover"
-	"io/ioutil"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	rootDir          = flag.String("path", ".", "Directory containing the go module to report on")
+	coverProfile     = flag.String("coverprofile", "", "Use an existing coverage profile instead of running 'go test', or '-' for stdin")
+	profileOut       = flag.String("profile-out", "", "Keep the coverage profile of the 'go test' run in this file, for 'go tool cover' and the like")
+	tmpDir           = flag.String("tmpdir", "", "Directory for temporary files like the coverage profile, instead of the system's")
+	noTest           = flag.Bool("no-test", false, "Don't run 'go test', just list every file that can be covered as uncovered")
+	timing           = flag.Bool("timing", false, "Write how long running 'go test' and parsing the profiles took to stderr")
+	merges           = listFlag("merge", "Coverage profile to merge with the others, like one from a separate test run (can be repeated)")
+	goBinary         = flag.String("go", "go", "The go binary to run 'go test' with, to pin a specific toolchain")
+	modulePrefix     = flag.String("module", "", "Module path to use for -path instead of the one in its go.mod, or its import path when it has none")
+	coverMode        = flag.String("covermode", "", "Coverage mode to pass to 'go test': set, count, or atomic")
+	partial          = flag.Bool("partial", false, "Add the number of weakly covered statements, which ran no more than -weak-count times, to the table")
+	weakCount        = flag.Int("weak-count", 1, "The most times a statement can have run and still count as weakly covered with -partial")
+	modMode          = flag.String("mod", "", "Module download mode to pass to 'go test': readonly, vendor, or mod")
+	packages         = flag.String("packages", "./...", "Relative package pattern to pass to 'go test' and restrict the table to, like ./internal/...")
+	cgo              = flag.String("cgo", "auto", "Whether to build with cgo: on, off, or auto to leave it to the go command")
+	race             = flag.Bool("race", false, "Pass -race to 'go test', which implies -covermode=atomic")
+	short            = flag.Bool("short", false, "Pass -short to 'go test'")
+	parallel         = flag.Int("p", 0, "Number of packages 'go test' may test in parallel (default GOMAXPROCS)")
+	count            = flag.Int("count", 0, "Number of times 'go test' runs each test, 1 disables the test cache")
+	timeout          = flag.String("timeout", "", "Timeout to pass to 'go test', like 5m, after which it panics (default 10m)")
+	shuffle          = flag.String("shuffle", "", "Pass -shuffle to 'go test': on, off, or an integer seed")
+	failFast         = flag.Bool("failfast", false, "Pass -failfast to 'go test', so it stops after the first failing test")
+	tags             = flag.String("tags", "", "Comma separated build tags for both finding go files and 'go test'")
+	testArgs         = flag.String("test-args", "", "Extra arguments to pass to 'go test', separated by spaces")
+	output           = flag.String("output", "", "Write the report to this file instead of stdout")
+	colorMode        = flag.String("color", "auto", "When to color the table: auto, always, never")
+	thresholds       = flag.String("color-thresholds", "40,60,80,90", "Comma separated percentages at which the color changes to red, yellow, green, and bright green")
+	diffRef          = flag.String("diff", "", "Only report coverage of lines changed since this git ref, like origin/main")
+	baselineFile     = flag.String("baseline", "", "JSON report from a previous run to show the change in coverage against")
+	compareRef       = flag.String("compare", "", "Compare coverage against this git ref, collected in a temporary worktree")
+	format           = flag.String("format", "table", "Output format: "+strings.Join(coveragetable.FormatNames(), ", "))
+	by               = flag.String("by", "file", "Group coverage rows by: file, package, func")
+	depth            = flag.Int("depth", 0, "Group files by the directories at most this many levels deep, like 1 for top-level directories")
+	sortBy           = flag.String("sort", "name", "Sort rows by: name, coverage")
+	precision        = flag.Int("precision", coveragetable.DefaultPrecision, "Number of decimals to show percentages with")
+	grade            = flag.Bool("grade", false, "Show a letter grade from A to F for the total coverage, using the color thresholds")
+	stable           = flag.Bool("stable", false, "Leave out timestamps, so the same coverage always gives the same output")
+	header           = flag.Bool("header", false, "Show when the report was generated, and from which git commit")
+	noFooter         = flag.Bool("no-footer", false, "Leave out the Total row of the table, csv, and markdown formats")
+	noHeader         = flag.Bool("no-header", false, "Leave out the row naming the columns of the table")
+	noColorTotal     = flag.Bool("no-color-total", false, "Render the Total row of the table without color")
+	uncovered        = flag.Bool("uncovered", false, "Only list the files without any coverage, one per line")
+	summary          = flag.Bool("summary", false, "Only show the total coverage")
+	countEmpty       = flag.Bool("count-empty", false, "Show files without statements as 0% and hold them to -file-threshold")
+	quiet            = flag.Bool("quiet", false, "Only write errors to stderr, same as -log-level=error")
+	logLevelName     = flag.String("log-level", "warn", "Least important diagnostics to write to stderr: "+strings.Join(logLevelNames(), ", "))
+	verbose          = flag.Bool("verbose", false, "Show the number of covered and total statements for each row")
+	abs              = flag.Bool("abs", false, "Show absolute paths instead of paths relative to -path")
+	relativeTo       = flag.String("relative-to", "", "Show paths relative to this directory instead of -path, like . for the current directory")
+	grep             = flag.String("grep", "", "Only show rows whose path contains this, ignoring case")
+	grepTotal        = flag.Bool("grep-affects-total", false, "Only count the rows matching -grep towards the Total row")
+	top              = flag.Int("top", 0, "Only show the N least covered rows, the total still includes every row")
+	reverse          = flag.Bool("reverse", false, "Reverse the sort order of rows")
+	includes         = listFlag("include", "Glob pattern of files to restrict the table to (can be repeated)")
+	excludes         = listFlag("exclude", "Glob pattern of files to leave out of the table (can be repeated)")
+	excludeFrom      = listFlag("exclude-from", "File with a glob pattern of files to leave out of the table on every line (can be repeated)")
+	excludeDirs      = listFlag("exclude-dir", "Glob pattern of directories not to look for go files in (can be repeated)")
+	includeVendor    = flag.Bool("include-vendor", false, "Look for go files in vendor directories too")
+	includeMain      = flag.Bool("include-main", false, "Include files in package main")
+	ignoreGenerated  = flag.Bool("ignore-generated", true, "Leave out files with a 'Code generated ... DO NOT EDIT.' header")
+	includeMocks     = flag.Bool("include-mocks", false, "Count files in mocks directories towards coverage")
+	excludeHelpers   = flag.Bool("exclude-test-helpers", false, "Leave out test helpers in regular go files, named like *_helpers.go or *_testutil.go")
+	testHelpers      = listFlag("test-helper", "Glob pattern of test helper files for -exclude-test-helpers to leave out on top of the usual names (can be repeated)")
+	respectGitignore = flag.Bool("respect-gitignore", false, "Skip files and directories ignored by .gitignore files")
+	followSymlinks   = flag.Bool("follow-symlinks", false, "Walk into symlinked directories when looking for go files")
+	dryRun           = flag.Bool("dry-run", false, "List the go files that would be covered and the 'go test' commands that would run, without running them")
+	watchMode        = flag.Bool("watch", false, "Render the report again whenever a go file under the path changes")
+	tuiMode          = flag.Bool("tui", false, "Browse the report interactively, falling back to the table when not in a terminal")
+	sourceFile       = flag.String("file", "", "Print the source of a file with every line marked as covered or not, instead of the table")
+	maxUnprofiled    = flag.Float64("max-unprofiled", 0.5, "Warn when more than this fraction of go files is missing from the coverage profile")
+	strict           = flag.Bool("strict", false, "Fail instead of warning when too many go files are missing from the coverage profile")
+	minFiles         = flag.Int("min-files", 0, "Fail when fewer go files than this are found, which usually means a wrong path")
+	historyFile      = flag.String("history", "", "Append the total coverage of every run to this file, to follow the trend")
+	historyShow      = flag.Int("history-show", 0, "Show the total coverage of the last N runs in the -history file as a sparkline")
+	annotate         = flag.String("annotate", "", "Also write an annotation for every file below -file-threshold, in this format: github")
+	threshold        = flag.Float64("threshold", 0, "Exit with code 1 when total coverage is below this percentage")
+	fileThreshold    = flag.Float64("file-threshold", 0, "Exit with code 1 when any file's coverage is below this percentage")
+)
+
+// mocksPattern is excluded by default so mocks don't count towards coverage
+const mocksPattern = "**/mocks/**"
+
+// testHelperPatterns are the usual names of test helpers kept in regular go files, excluded with -exclude-test-helpers
+var testHelperPatterns = []string{"*_helpers.go", "*_testutil.go"}
+
+// vendorPattern is excluded from the walk by default, as vendored code isn't tested by 'go test ./...' anyway
+const vendorPattern = "vendor"
+
+// stringList is a flag that can be given multiple times, collecting every value
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func listFlag(name, usage string) *stringList {
+	var l stringList
+	flag.Var(&l, name, usage)
+	return &l
+}
+
+// errGatesFailed is returned by run when the report was rendered, but coverage is below one of the thresholds. Every
+// failing gate has already been reported by then.
+var errGatesFailed = errors.New("coverage is below threshold")
+
+// Exit codes tell coverage that's too low apart from coverage-table not getting as far as checking it, like the
+// status of 2 the flag package already exits with for bad flags
+const (
+	exitGatesFailed = 1
+	exitError       = 2
 )
 
 func main() {
-	path := ""
-	if len(os.Args) == 2 {
-		path = os.Args[1]
+	flag.Parse()
+	coveragetable.Logf = logf
+
+	// Exiting only happens here, so everything deferred by run has had a chance to clean up
+	if err := run(); err != nil {
+		if errors.Is(err, errGatesFailed) {
+			os.Exit(exitGatesFailed)
+		}
+		errorf("%s", err)
+		os.Exit(exitError)
 	}
+}
 
-	files, err := findGoFiles(path)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Unable to walk path for go files:", err)
-		os.Exit(1)
+// run generates the coverage report as configured by the flags, and checks it against the thresholds
+func run() error {
+	// Set up logging first, so nothing below is written at the wrong level
+	level, ok := logLevels[*logLevelName]
+	if !ok {
+		return fmt.Errorf("Unknown log level %q, expected one of: %s", *logLevelName, strings.Join(logLevelNames(), ", "))
+	}
+	if *quiet {
+		level = coveragetable.LevelError
+	}
+	minLogLevel = level
+
+	root := *rootDir
+	switch arg := flag.Arg(0); {
+	case flag.NArg() != 1:
+	case arg == "-":
+		// A bare '-' is shorthand for reading the coverage profile from stdin
+		*coverProfile = "-"
+	default:
+		warnf("passing the directory as an argument is deprecated, use -path instead")
+		root = arg
 	}
 
-	modName, err := modulePath(path)
+	// Resolve the directory right away, so everything below sees the same one no matter where it's used from
+	root, err := filepath.Abs(root)
 	if err != nil {
-		abs, _ := filepath.Abs(path)
-		fmt.Fprintf(os.Stderr, "Unable to find go mod file in %s: %s\n", abs, err)
-		os.Exit(1)
+		return fmt.Errorf("Unable to resolve path %s: %w", root, err)
 	}
-	baseName := filepath.Base(modName)
 
-	// We want to store coverage results in a temporary file so we're not cluttering things up
-	f, err := ioutil.TempFile("", fmt.Sprintf("%s-*.out", baseName))
+	c, err := loadConfig(root)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Unable to create temporary file for coverage data:", err)
-		os.Exit(1)
+		return fmt.Errorf("Unable to read %s: %w", configFile, err)
+	}
+	if err := c.apply(); err != nil {
+		return fmt.Errorf("Invalid value in %s: %w", configFile, err)
 	}
-	defer os.Remove(f.Name())
 
-	cmd := exec.Command("go", "test", "-coverprofile", f.Name(), "./...")
-	cmd.Dir = path
-	if err := cmd.Run(); err != nil {
-		fmt.Fprintln(os.Stderr, "Unable to run 'go test':", err)
-		os.Exit(1)
+	// Finding out the temporary directory can't be used only after the tests ran would waste the whole run
+	if *tmpDir != "" {
+		f, err := os.CreateTemp(*tmpDir, "coverage-table-*")
+		if err != nil {
+			return fmt.Errorf("Unable to use -tmpdir %s for temporary files: %w", *tmpDir, err)
+		}
+		f.Close()
+		if err := os.Remove(f.Name()); err != nil {
+			return fmt.Errorf("Unable to use -tmpdir %s for temporary files: %w", *tmpDir, err)
+		}
 	}
 
-	if err := printCoverTable(modName, files, f.Name()); err != nil {
-		fmt.Fprintln(os.Stderr, "Unable to generate coverage table:", err)
-		os.Exit(1)
+	// Every profile given is merged into one, with -coverprofile being just the first of them
+	var profileNames []string
+	if *coverProfile != "" {
+		profileNames = append(profileNames, *coverProfile)
+	}
+	profileNames = append(profileNames, *merges...)
+	for _, name := range profileNames {
+		if name == "-" {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("Unable to read coverage profile: %w", err)
+		}
+		f.Close()
 	}
-}
 
-func findGoFiles(path string) (map[string]float64, error) {
-	files := make(map[string]float64)
+	if !coveragetable.IsFormat(*format) {
+		return fmt.Errorf("Unknown format %q, expected one of: %s", *format, strings.Join(coveragetable.FormatNames(), ", "))
+	}
+	opts := coveragetable.Options{Format: *format, Verbose: *verbose, Summary: *summary, NoFooter: *noFooter}
+	opts.NoColorTotal = *noColorTotal
+	opts.NoHeader = *noHeader
+	opts.Grade = *grade
+	opts.FileThreshold = *fileThreshold
+	opts.Stable = *stable
+	if *stable && *header {
+		return errors.New("-header shows the time of the run, which -stable leaves out")
+	}
+	opts.Precision = *precision
+	if *precision < 0 {
+		return fmt.Errorf("-precision must be positive, got %d", *precision)
+	}
 
-	ap, err := filepath.Abs(path)
+	switch *colorMode {
+	case "always":
+		opts.Color = true
+	case "never":
+		opts.Color = false
+	case "auto":
+		// Escape codes would only clutter up files and pipes
+		opts.Color = *output == "" && term.IsTerminal(int(os.Stdout.Fd()))
+	default:
+		return fmt.Errorf("Unknown color mode %q, expected one of: auto, always, never", *colorMode)
+	}
+
+	t, err := coveragetable.ParseColorThresholds(*thresholds)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to get absolute path for %s: %s\n", path, err)
-		ap = path
+		return fmt.Errorf("Invalid color thresholds %q: %w", *thresholds, err)
 	}
+	opts.ColorThresholds = t
 
-	if err := filepath.Walk(ap, func(p string, fi os.FileInfo, err error) error {
+	for _, name := range *excludeFrom {
+		patterns, err := readPatterns(name)
 		if err != nil {
-			fmt.Printf("Prevent panic by handling failure accessing a path: %s\n", err)
-			return err
+			return fmt.Errorf("Unable to read exclude patterns: %w", err)
 		}
+		*excludes = append(*excludes, patterns...)
+	}
 
-		// Skip hidden directories/files
-		if fi.IsDir() && strings.HasPrefix(fi.Name(), ".") {
-			return filepath.SkipDir
+	for _, pattern := range append(append(append(*includes, *excludes...), *excludeDirs...), *testHelpers...) {
+		if err := coveragetable.ValidateGlob(pattern); err != nil {
+			return fmt.Errorf("Invalid pattern %q: %w", pattern, err)
 		}
+	}
 
-		// Skip 'testdata' directories, as the go tool will ignore those as well
-		if fi.IsDir() && fi.Name() == "testdata" {
-			return filepath.SkipDir
-		}
+	if *annotate != "" && *annotate != "github" {
+		return fmt.Errorf("Unknown annotation format %q, expected: github", *annotate)
+	}
+	// Annotations go to stdout after the report, where they'd break anything that's meant to be parsed
+	if *annotate != "" && *output == "" && *format != "table" && *format != "markdown" {
+		return fmt.Errorf("-annotate writes to stdout, so -format=%s needs -output to keep the report readable", *format)
+	}
+	if *historyShow < 0 {
+		return fmt.Errorf("-history-show must be positive, got %d", *historyShow)
+	}
+	if *historyShow > 0 && *historyFile == "" {
+		return errors.New("-history-show needs a -history file to read from")
+	}
+	if *top < 0 {
+		return fmt.Errorf("-top must be positive, got %d", *top)
+	}
+	if *sortBy != "name" && *sortBy != "coverage" {
+		return fmt.Errorf("Unknown sort order %q, expected one of: name, coverage", *sortBy)
+	}
 
-		if strings.HasSuffix(fi.Name(), ".go") {
-			// Ignore test files
-			if strings.HasSuffix(fi.Name(), "_test.go") {
-				return nil
-			}
+	if *parallel < 0 {
+		return fmt.Errorf("Invalid -p %d, expected a positive number of packages", *parallel)
+	}
+	if *count < 0 {
+		return fmt.Errorf("Invalid -count %d, expected a positive number of runs", *count)
+	}
 
-			// Skip if this is a file in package main
-			file, err := os.Open(p)
-			if err != nil { return err }
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				if scanner.Text() == "package main" {
-					return nil
-				}
-			}
+	base := *relativeTo
+	if base != "" {
+		if *abs {
+			return errors.New("-abs and -relative-to can't be combined")
+		}
+		if base, err = filepath.Abs(base); err != nil {
+			return fmt.Errorf("Unable to resolve path %s: %w", *relativeTo, err)
+		}
+	}
 
-			// TODO: Also skip go files that only contain interfaces
+	// Only needed when running the tests, a missing toolchain shouldn't get in the way of reading a profile
+	if len(profileNames) == 0 {
+		path, err := exec.LookPath(*goBinary)
+		if err != nil {
+			return fmt.Errorf("Unable to find go binary: %w", err)
+		}
+		*goBinary = path
+	}
 
-			// Clean up path to match what
-			fp := strings.TrimPrefix(p, ap)
-			fp = strings.ReplaceAll(fp, "\\", "/")
-			fp = strings.TrimPrefix(fp, "/")
+	pkgs, err := parsePackagePattern(*packages)
+	if err != nil {
+		return fmt.Errorf("Invalid -packages: %w", err)
+	}
 
-			files[fp] = 0
+	if *timeout != "" {
+		if _, err := time.ParseDuration(*timeout); err != nil {
+			return fmt.Errorf("Invalid -timeout %q: %w", *timeout, err)
 		}
+	}
 
+	switch *coverMode {
+	case "", "set", "count", "atomic":
+	default:
+		return fmt.Errorf("Unknown cover mode %q, expected one of: set, count, atomic", *coverMode)
+	}
+	moduleSet := false
+	flag.Visit(func(f *flag.Flag) {
+		moduleSet = moduleSet || f.Name == "module"
+	})
+	if moduleSet || *modulePrefix != "" {
+		if err := module.CheckImportPath(*modulePrefix); err != nil {
+			return fmt.Errorf("Invalid -module %q: %w", *modulePrefix, err)
+		}
+	}
+	if *partial && *weakCount < 1 {
+		return fmt.Errorf("-weak-count must be at least 1, got %d", *weakCount)
+	}
+	if *partial && *coverMode == "set" {
+		warnf("-covermode=set only records whether a block ran, so -partial counts every covered statement as weak")
+	}
+	if *race && *coverMode != "" && *coverMode != "atomic" {
+		return fmt.Errorf("-race needs -covermode=atomic, got %s", *coverMode)
+	}
+	if err := checkShuffle(*shuffle); err != nil {
+		return err
+	}
+	switch *cgo {
+	case "auto", "on", "off":
+	default:
+		return fmt.Errorf("Unknown cgo setting %q, expected one of: auto, on, off", *cgo)
+	}
+	switch *modMode {
+	case "", "readonly", "vendor", "mod":
+	default:
+		return fmt.Errorf("Unknown module mode %q, expected one of: readonly, vendor, mod", *modMode)
+	}
+
+	switch *by {
+	case "file", "package", "func":
+	default:
+		return fmt.Errorf("Unknown grouping %q, expected one of: file, package, func", *by)
+	}
+	// Line based formats describe files, so there's nothing to put in them for a package or a function
+	if *depth < 0 {
+		return fmt.Errorf("-depth must be positive, got %d", *depth)
+	}
+	if *depth > 0 && *by == "func" {
+		return errors.New("-depth groups by directory, so it can't be combined with -by=func")
+	}
+	if (*format == "lcov" || *format == "cobertura" || *format == "sarif") && (*by != "file" || *depth > 0) {
+		return fmt.Errorf("-format=%s only supports -by=file", *format)
+	}
+	if *format == "sarif" && *fileThreshold == 0 {
+		warnf("-format=sarif only reports files below -file-threshold, which isn't set")
+	}
+
+	if *noTest && len(profileNames) > 0 {
+		return errors.New("-no-test lists the files without any coverage, so it can't be combined with -coverprofile or -merge")
+	}
+	if *noTest && (*compareRef != "" || *watchMode || *profileOut != "") {
+		return errors.New("-no-test doesn't run any tests, so it can't be combined with -compare, -watch, or -profile-out")
+	}
+	if *profileOut != "" && len(profileNames) > 0 {
+		warnf("-profile-out keeps the profile of a 'go test' run, but none is run with -coverprofile or -merge")
+	}
+	if *tuiMode && *output != "" {
+		return errors.New("-tui is interactive, so it can't be combined with -output")
+	}
+	if *compareRef != "" && (*baselineFile != "" || *diffRef != "") {
+		return errors.New("-compare can't be combined with -baseline or -diff")
+	}
+
+	if *watchMode {
+		// Watching only makes sense when the tests are run again after every change
+		if len(profileNames) > 0 {
+			return errors.New("-watch runs 'go test' itself, so it can't be combined with -coverprofile or -merge")
+		}
+		if err := watch(root); err != nil {
+			return fmt.Errorf("Unable to watch for changes: %w", err)
+		}
 		return nil
-	}); err != nil {
-		return nil, err
 	}
 
-	return files, nil
-}
+	found, repo, filter, err := findFiles(root, pkgs)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		return printDryRun(os.Stdout, root, repo, found, filter, profileNames, pkgs, *noTest)
+	}
 
-func printCoverTable(modName string, files map[string]float64, file string) error {
-	profiles, err := cover.ParseProfiles(file)
+	r, err := collect(root, repo, found, filter, profileNames, pkgs, *profileOut)
 	if err != nil {
 		return err
 	}
+	if len(r.Rows) == 0 {
+		warnf("no files counted toward coverage, check -include and -exclude")
+	}
 
-	// Calculate coverage percentage for files in coverage report
-	for _, profile := range profiles {
-		name := strings.Replace(profile.FileName, modName+"/", "", 1)
-		cov := percentCovered(profile)
+	if *sourceFile != "" {
+		return printSource(root, r, opts)
+	}
+
+	if *partial {
+		r = coveragetable.CountWeak(r, *weakCount)
+		opts.Verbose = true
+		opts.Partial = true
+	}
+
+	// Diff coverage replaces the whole report, so the gates apply to the changed lines as well
+	if *diffRef != "" {
+		changed, err := coveragetable.ChangedLines(root, *diffRef)
+		if err != nil {
+			return fmt.Errorf("Unable to find changed lines: %w", err)
+		}
+		r = coveragetable.DiffTable(r, changed)
+		// The number of changed statements is the point of a diff report
+		opts.Verbose = true
+	}
+
+	display, err := group(root, r)
+	if err != nil {
+		return err
+	}
+	opts.Name = groupName()
+	if *grep != "" {
+		display = coveragetable.Grep(display, *grep, *grepTotal)
+	}
+	if *top > 0 {
+		display = coveragetable.Top(display, *top)
+	}
+	if *sortBy == "coverage" {
+		coveragetable.SortRowsByCoverage(display.Rows)
+	}
+	if *reverse {
+		for i, j := 0, len(display.Rows)-1; i < j; i, j = i+1, j-1 {
+			display.Rows[i], display.Rows[j] = display.Rows[j], display.Rows[i]
+		}
+	}
+	if *summary {
+		display.Rows = nil
+	}
+	if *baselineFile != "" {
+		if display.Baseline, err = coveragetable.LoadBaseline(*baselineFile); err != nil {
+			return fmt.Errorf("Unable to read baseline: %w", err)
+		}
+	}
+	if *compareRef != "" {
+		if display.Baseline, err = compareBaseline(root, *compareRef, pkgs); err != nil {
+			return fmt.Errorf("Unable to collect coverage of %s: %w", *compareRef, err)
+		}
+		// Both sides of the comparison are the point of it
+		opts.ShowBaseline = true
+	}
+	if *header {
+		h := coveragetable.NewHeader(root)
+		display.Header = &h
+	}
+	// Names stay relative to root up to here, as that's what every file is known by internally
+	names := func(r coveragetable.Table) (coveragetable.Table, error) {
+		switch {
+		case *abs:
+			return coveragetable.AbsNames(root, r), nil
+		case *relativeTo != "":
+			return coveragetable.RelativeNames(root, base, r)
+		}
+		return r, nil
+	}
+	if display, err = names(display); err != nil {
+		return fmt.Errorf("Unable to show paths relative to %s: %w", base, err)
+	}
 
-		if _, ok := files[name]; !ok {
-			fmt.Fprintln(os.Stderr, "File not in files map:", name)
-			for name, _ := range files {
-				fmt.Fprintln(os.Stderr, "File:", name)
+	// Browsing takes the place of rendering, the gates and everything after them still apply once it's done
+	browsed := false
+	if *tuiMode {
+		if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+			// Functions are looked up by the names of the rows, so it takes the directory they're relative to
+			dir := root
+			if *relativeTo != "" {
+				dir = base
+			}
+			if err := tui(dir, display, *by == "file" && *depth == 0, opts); err != nil {
+				return fmt.Errorf("Unable to browse coverage report: %w", err)
 			}
-			return errors.New("unknown file: " + name)
+			browsed = true
+		} else {
+			warnf("-tui needs a terminal to run in, showing the table instead")
 		}
+	}
 
-		files[name] = cov
+	if !browsed {
+		render := func(w io.Writer) error {
+			return display.Render(w, opts)
+		}
+		if *uncovered {
+			// The list is of files, however the table would have been grouped
+			files, err := names(r)
+			if err != nil {
+				return fmt.Errorf("Unable to show paths relative to %s: %w", base, err)
+			}
+			render = func(w io.Writer) error {
+				return printUncovered(w, files)
+			}
+		}
+
+		if *output == "" {
+			err = render(os.Stdout)
+		} else {
+			err = renderFile(*output, render)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to render coverage report: %w", err)
+		}
 	}
 
-	// Used for sorted list later
-	names := make([]string, 0, len(files))
-	// Used for averaging coverage
-	var percents []float64
+	// A run that fails the gates is still part of the trend
+	if *historyFile != "" {
+		h := coveragetable.NewHeader(root)
+		entry := coveragetable.HistoryEntry{Time: h.GeneratedAt, Commit: h.Commit, Total: r.Total.Percent()}
+		if err := coveragetable.AppendHistory(*historyFile, entry); err != nil {
+			return fmt.Errorf("Unable to write coverage history: %w", err)
+		}
+	}
+	if *historyShow > 0 {
+		entries, err := coveragetable.LoadHistory(*historyFile)
+		if err != nil {
+			return fmt.Errorf("Unable to read coverage history: %w", err)
+		}
+		if len(entries) > *historyShow {
+			entries = entries[len(entries)-*historyShow:]
+		}
+		// Written to stderr, as the report may be going to stdout in a format the sparkline would only break. It was asked
+		// for, so unlike the diagnostics it's shown even with -quiet.
+		fmt.Fprintf(os.Stderr, "Coverage history: %s\n", coveragetable.Sparkline(entries, *precision))
+	}
 
-	for n, p := range files {
-		// Mocks don't count towards coverage
-		if strings.Contains(n, "mocks/") {
-			continue
+	if *annotate == "github" {
+		if err := annotateGitHub(os.Stdout, root, r); err != nil {
+			return fmt.Errorf("Unable to write annotations: %w", err)
 		}
+	}
 
-		names = append(names, n)
-		percents = append(percents, p)
+	// Check every gate before exiting so all failures are reported at once
+	failed := false
+	if total := r.Total.Percent(); total < *threshold {
+		errorf("coverage %.*f%% is below threshold %.*f%%", *precision, total, *precision, *threshold)
+		failed = true
+	}
+	for _, row := range r.Rows {
+		if cov := row.Percent(); !row.Empty && cov < *fileThreshold {
+			errorf("%s: coverage %.*f%% is below file threshold %.*f%%", row.Name, *precision, cov, *precision, *fileThreshold)
+			failed = true
+		}
+	}
+	if failed {
+		return errGatesFailed
 	}
 
-	// Sort so we can go through the files in lexicographical order
-	sort.Strings(names)
+	return nil
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+// annotateGitHub writes a GitHub Actions warning for the total and every file below their threshold to w, so they show
+// up on the pull request. Files are named relative to the workspace, which is where GitHub looks for them.
+func annotateGitHub(w io.Writer, root string, r coveragetable.Table) error {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		workspace = "."
+	}
+	workspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return err
+	}
+	files, err := coveragetable.RelativeNames(root, workspace, r)
+	if err != nil {
+		return err
+	}
 
-	for _, name := range names {
-		cov := files[name]
-		table.Rich([]string{name, fmt.Sprintf("%.2f", cov)}, colorsForPercent(cov))
+	if total := files.Total.Percent(); total < *threshold {
+		msg := fmt.Sprintf("coverage is %.*f%%, below threshold %.*f%%", *precision, total, *precision, *threshold)
+		if _, err := fmt.Fprintf(w, "::warning::%s\n", githubEscaper.Replace(msg)); err != nil {
+			return err
+		}
+	}
+	for _, row := range files.Rows {
+		cov := row.Percent()
+		if row.Empty || cov >= *fileThreshold {
+			continue
+		}
+		msg := fmt.Sprintf("coverage is %.*f%%, below file threshold %.*f%%", *precision, cov, *precision, *fileThreshold)
+		file := githubPropertyEscaper.Replace(row.Name)
+		if _, err := fmt.Fprintf(w, "::warning file=%s::%s\n", file, githubEscaper.Replace(msg)); err != nil {
+			return err
+		}
 	}
 
-	avg := avg(percents)
-	table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
-	table.SetFooter([]string{"Total", fmt.Sprintf("%.2f", avg)})
-	table.SetFooterColor(colorsForPercent(avg)...)
-	table.Render()
+	return nil
+}
+
+// githubEscaper escapes the message of a GitHub Actions workflow command, githubPropertyEscaper its properties
+var (
+	githubEscaper         = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// printSource writes the -file source with its covered lines marked, to -output or stdout. The file can be given
+// relative to the working directory or as an absolute path, but it has to be under root.
+func printSource(root string, r coveragetable.Table, opts coveragetable.Options) error {
+	name, err := filepath.Abs(*sourceFile)
+	if err != nil {
+		return fmt.Errorf("Unable to resolve path %s: %w", *sourceFile, err)
+	}
+	rel, err := filepath.Rel(root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("Unable to show %s: it is outside of %s", *sourceFile, root)
+	}
+
+	render := func(w io.Writer) error {
+		return coveragetable.PrintSource(w, root, filepath.ToSlash(rel), r, opts)
+	}
+	if *output == "" {
+		err = render(os.Stdout)
+	} else {
+		err = renderFile(*output, render)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to show coverage of %s: %w", *sourceFile, err)
+	}
 
 	return nil
 }
 
-func colorsForPercent(cov float64) []tablewriter.Colors {
-	switch {
-	// cov == 0 means that there are no tests covering the file at all
-	case cov == 0:
-		return []tablewriter.Colors{{tablewriter.FgHiRedColor}, {tablewriter.FgHiRedColor}}
-	case cov < 40:
-		return []tablewriter.Colors{{}, {tablewriter.FgHiRedColor}}
-	case cov < 60:
-		return []tablewriter.Colors{{}, {tablewriter.FgRedColor}}
-	case cov < 80:
-		return []tablewriter.Colors{{}, {tablewriter.FgYellowColor}}
-	case cov < 90:
-		return []tablewriter.Colors{{}, {tablewriter.FgGreenColor}}
-	default:
-		return []tablewriter.Colors{{}, {tablewriter.FgHiGreenColor}}
+// renderFile renders to the named file, replacing the file if it already exists
+func renderFile(name string, render func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := render(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// group combines the rows of the report for root as asked for by -by or -depth
+func group(root string, r coveragetable.Table) (coveragetable.Table, error) {
+	if *depth > 0 {
+		return coveragetable.GroupByDepth(r, *depth), nil
+	}
+
+	switch *by {
+	case "package":
+		return coveragetable.GroupByPackage(r), nil
+	case "func":
+		grouped, err := coveragetable.GroupByFunc(root, r)
+		if err != nil {
+			return grouped, fmt.Errorf("Unable to find functions in go files: %w", err)
+		}
+		return grouped, nil
+	}
+
+	return r, nil
+}
+
+// groupName returns what the rows returned by group are, for the header of the table
+func groupName() string {
+	if *depth > 0 {
+		return "Directory"
+	}
+
+	switch *by {
+	case "package":
+		return "Package"
+	case "func":
+		return "Function"
+	}
+
+	return "File"
+}
+
+// findFiles finds the go files to cover under root, restricted to the packages matching pkgs, along with the modules
+// they're in and the filter deciding which of them end up in the report
+func findFiles(root string, pkgs packagePattern) (found coveragetable.GoFiles, repo coveragetable.Repository, filter coveragetable.FileFilter, err error) {
+	dirs := *excludeDirs
+	// Vendored code isn't ours to test, unless asked for
+	if !*includeVendor {
+		dirs = append(dirs, vendorPattern)
+	}
+
+	ctx := build.Default
+	ctx.BuildTags = buildTags()
+	ctx.CgoEnabled = cgoEnabled()
+	found, err = coveragetable.FindGoFiles(root, coveragetable.WalkOptions{
+		IncludeMain:      *includeMain,
+		IgnoreGenerated:  *ignoreGenerated,
+		ExcludeDirs:      dirs,
+		FollowSymlinks:   *followSymlinks,
+		RespectGitignore: *respectGitignore,
+		Build:            &ctx,
+	})
+	if err != nil {
+		return found, repo, filter, fmt.Errorf("Unable to walk path for go files: %w", err)
+	}
+
+	repo, err = coveragetable.FindModules(root)
+	if errors.Is(err, coveragetable.ErrNoModules) {
+		repo, err = gopathRepository(root)
+	} else if err == nil && *modulePrefix != "" {
+		// With a single module that's the one meant, even when it's further down, otherwise it has to be the one at root
+		dir := "."
+		if dirs := repo.TestDirs(); len(dirs) == 1 {
+			dir = dirs[0]
+		}
+		debugf("using %s as the module path of %s", *modulePrefix, filepath.Join(root, dir))
+		repo, err = repo.WithModulePath(dir, *modulePrefix)
+	}
+	if err != nil {
+		return found, repo, filter, fmt.Errorf("Unable to find go modules in %s: %w", root, err)
+	}
+
+	// Files in packages that aren't tested would only show up as uncovered, like those in a module go.work doesn't use
+	for name := range found.Files {
+		if !pkgs.matchesFile(name) || !repo.Tested(name) {
+			delete(found.Files, name)
+			delete(found.Empty, name)
+		}
+	}
+
+	// Rendering an empty table would only hide a wrong path
+	if len(found.Files) == 0 {
+		return found, repo, filter, fmt.Errorf("No go files to cover found in %s", root)
+	}
+	if len(found.Files) < *minFiles {
+		return found, repo, filter, fmt.Errorf("Found %d go files in %s, expected at least %d", len(found.Files), root, *minFiles)
+	}
+
+	filter = coveragetable.FileFilter{Includes: *includes, Excludes: *excludes}
+	// Mocks don't count towards coverage unless asked for
+	if !*includeMocks {
+		filter.Excludes = append(filter.Excludes, mocksPattern)
+	}
+	if *excludeHelpers {
+		filter.Excludes = append(append(filter.Excludes, testHelperPatterns...), *testHelpers...)
 	}
+
+	return found, repo, filter, nil
 }
 
-func modulePath(path string) (string, error) {
-	bytes, err := ioutil.ReadFile(filepath.Join(path, "go.mod"))
+// gopathRepository returns the repository for a root that isn't in a module, as the package given by -module or
+// otherwise by where root is in GOPATH
+func gopathRepository(root string) (coveragetable.Repository, error) {
+	if *modulePrefix != "" {
+		debugf("no go.mod found, using %s as the import path of %s", *modulePrefix, root)
+		return coveragetable.NewRepository(root, *modulePrefix), nil
+	}
+
+	out, err := exec.Command(*goBinary, "env", "GOPATH").Output()
 	if err != nil {
-		return "", err
+		return coveragetable.Repository{}, fmt.Errorf("asking 'go env' for GOPATH: %w", err)
+	}
+	gopath := strings.TrimSpace(string(out))
+
+	repo, ok := coveragetable.GOPATHRepository(root, gopath)
+	if !ok {
+		return repo, fmt.Errorf("%w, and %s is not in GOPATH (%s), use -module to give its import path", coveragetable.ErrNoModules, root, gopath)
 	}
+	infof("no go.mod found, using %s in GOPATH mode", root)
 
-	return modfile.ModulePath(bytes), nil
+	return repo, nil
 }
 
-func percentCovered(p *cover.Profile) float64 {
-	var total int64
-	var covered int64
+// collect builds the report for the files found under root, from the named profiles or by running 'go test' when
+// there are none. The profile of a 'go test' run is kept in the file named by keep, unless it's empty.
+func collect(root string, repo coveragetable.Repository, found coveragetable.GoFiles, filter coveragetable.FileFilter, profileNames []string, pkgs packagePattern, keep string) (coveragetable.Table, error) {
+	var profiles []*cover.Profile
+	if len(profileNames) > 0 {
+		for _, name := range profileNames {
+			start := time.Now()
+			p, err := parseProfile(name)
+			if err != nil {
+				return coveragetable.Table{}, fmt.Errorf("Unable to parse coverage profile %s: %w", name, err)
+			}
+			timef(start, "parsing %s", name)
+			if *partial && len(p) > 0 && p[0].Mode == "set" {
+				warnf("%s was recorded with -covermode=set, which only records whether a block ran, so -partial counts every covered statement as weak", name)
+			}
+			profiles = append(profiles, p...)
+		}
+		if len(profileNames) > 1 {
+			merged, err := coveragetable.MergeProfiles(profiles)
+			if err != nil {
+				return coveragetable.Table{}, fmt.Errorf("Unable to merge coverage profiles: %w", err)
+			}
+			profiles = merged
+		}
+
+		// A profile we didn't generate ourselves may have come from a different module
+		if err := repo.CheckProfiles(profiles); err != nil {
+			return coveragetable.Table{}, fmt.Errorf("Coverage profile does not match module: %w", err)
+		}
+	} else if *noTest {
+		debugf("not running 'go test': every file is shown as uncovered with -no-test")
+	} else {
+		if repo.InGOPATH() && *modMode != "" {
+			return coveragetable.Table{}, errors.New("-mod only applies to modules, but there is no go.mod")
+		}
+		for _, dir := range repo.TestDirs() {
+			pattern, ok := pkgs.forModule(dir)
+			if !ok {
+				debugf("not running 'go test' in %s: no packages match -packages", filepath.Join(root, dir))
+				continue
+			}
+			infof("running 'go test' in %s", filepath.Join(root, dir))
+			p, err := runTests(filepath.Join(root, dir), repo.Name(dir), pattern, repo.InGOPATH())
+			if err != nil {
+				return coveragetable.Table{}, fmt.Errorf("Unable to collect coverage: %w", err)
+			}
+			profiles = append(profiles, p...)
+		}
+
+		if keep != "" {
+			if err := renderFile(keep, func(w io.Writer) error { return writeProfile(w, profiles) }); err != nil {
+				return coveragetable.Table{}, fmt.Errorf("Unable to write coverage profile: %w", err)
+			}
+			infof("coverage profile written to %s", keep)
+		}
+	}
+
+	// A profile missing most of the files usually means the tests didn't actually run, which would make the coverage that
+	// is there look a lot better than it is. Without tests there's no profile to miss them, on purpose.
+	if missing, counted := unprofiled(repo, found, profiles, filter); !*noTest && counted > 0 && float64(missing)/float64(counted) > *maxUnprofiled {
+		msg := fmt.Sprintf("%d of %d go files are missing from the coverage profile, check that the tests ran", missing, counted)
+		if *strict {
+			return coveragetable.Table{}, errors.New(msg)
+		}
+		warnf("%s", msg)
+	}
+
+	// Files without statements are shown as uncovered when asked for, like they used to be
+	if *countEmpty {
+		found.Empty = nil
+	}
+
+	r, err := coveragetable.BuildTable(repo, found, profiles, filter)
+	if err != nil {
+		return r, fmt.Errorf("Unable to generate coverage table: %w", err)
+	}
+
+	return r, nil
+}
 
-	for _, block := range p.Blocks {
-		total += int64(block.NumStmt)
-		if block.Count > 0 {
-			covered += int64(block.Count)
+// printUncovered writes the name of every file without any covered statements on a line of its own, leaving out files
+// that have nothing to cover in the first place
+func printUncovered(w io.Writer, r coveragetable.Table) error {
+	for _, row := range r.Rows {
+		if row.Empty || row.Covered > 0 {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, row.Name); err != nil {
+			return err
 		}
 	}
 
-	if total == 0 {
-		return 0
+	return nil
+}
+
+// unprofiled counts the files kept by filter that have statements to cover, and how many of those are missing from
+// the coverage profiles
+func unprofiled(repo coveragetable.Repository, found coveragetable.GoFiles, profiles []*cover.Profile, filter coveragetable.FileFilter) (missing, counted int) {
+	profiled := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		name, _ := repo.RelativeName(profile.FileName)
+		profiled[name] = true
+	}
+
+	for name := range found.Files {
+		if found.Empty[name] || !filter.Keep(name) {
+			continue
+		}
+		counted++
+		if !profiled[name] {
+			missing++
+		}
 	}
 
-	return float64(covered) / float64(total) * 100
+	return missing, counted
 }
 
-func avg(counts []float64) float64 {
-	var sum float64
+// printDryRun writes the files that would end up in the report to w, followed by the 'go test' command for every module
+// or the profiles that would be read instead, unless noTest says no tests would run
+func printDryRun(w io.Writer, root string, repo coveragetable.Repository, found coveragetable.GoFiles, filter coveragetable.FileFilter, profileNames []string, pkgs packagePattern, noTest bool) error {
+	names := make([]string, 0, len(found.Files))
+	for name := range found.Files {
+		if filter.Keep(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
 
-	for _, count := range counts {
-		sum += count
+	fmt.Fprintf(w, "%d go files would be covered:\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", name)
 	}
 
-	return sum / float64(len(counts))
+	if len(profileNames) > 0 {
+		fmt.Fprintln(w, "Coverage would be read from:")
+		for _, name := range profileNames {
+			if name == "-" {
+				name = "stdin"
+			}
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+		return nil
+	}
+
+	if noTest {
+		_, err := fmt.Fprintln(w, "No tests would run, every file would be shown as uncovered")
+		return err
+	}
+
+	fmt.Fprintln(w, "Commands that would run:")
+	for _, dir := range repo.TestDirs() {
+		pattern, ok := pkgs.forModule(dir)
+		if !ok {
+			continue
+		}
+		args := goTestArgs("<temporary file>", pattern)
+		for i, arg := range args {
+			if strings.ContainsAny(arg, " \t\"'<>") {
+				args[i] = strconv.Quote(arg)
+			}
+		}
+		env := ""
+		if repo.InGOPATH() {
+			env = "GO111MODULE=off "
+		}
+		_, err := fmt.Fprintf(w, "  cd %s && %s%s %s\n", filepath.Join(root, dir), env, *goBinary, strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
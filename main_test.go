@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// setBool sets the flag p points to for the rest of the test
+func setBool(t *testing.T, p *bool, value bool) {
+	old := *p
+	*p = value
+	t.Cleanup(func() { *p = old })
+}
+
+// keptFiles returns the names of the go files findFiles finds under the fixture in testdata/dir that its filter keeps,
+// sorted
+func keptFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	pkgs, err := parsePackagePattern("./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _, filter, err := findFiles(filepath.Join("testdata", dir), pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for name := range found.Files {
+		if filter.Keep(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestFindFilesSkipsMocks(t *testing.T) {
+	want := []string{"double.go", "store/store.go"}
+	if got := keptFiles(t, "mocks"); !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestFindFilesIncludeMocks(t *testing.T) {
+	setBool(t, includeMocks, true)
+
+	want := []string{"double.go", "mocks/store.go", "store/mocks/store.go", "store/store.go"}
+	if got := keptFiles(t, "mocks"); !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestFindFilesSkipsVendor(t *testing.T) {
+	want := []string{"shout.go"}
+	if got := keptFiles(t, "vendored"); !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestFindFilesIncludeVendor(t *testing.T) {
+	setBool(t, includeVendor, true)
+
+	want := []string{"internal/vendor/pick.go", "shout.go", "vendor/github.com/acme/strs/strs.go"}
+	if got := keptFiles(t, "vendored"); !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
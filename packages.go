@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// packagePattern is a -packages pattern, which names a directory relative to the path and optionally everything below
+// it, just like the relative patterns 'go test' accepts
+type packagePattern struct {
+	// dir is the slash-separated directory the pattern starts at, relative to the path
+	dir string
+	// recursive is set for patterns ending in '/...'
+	recursive bool
+}
+
+// parsePackagePattern parses a relative package pattern like './...' or './internal/api'. Import paths and wildcards
+// anywhere but at the end aren't supported, as the files they match couldn't be found without asking 'go list'.
+func parsePackagePattern(s string) (packagePattern, error) {
+	var p packagePattern
+
+	if s != "." && !strings.HasPrefix(s, "./") {
+		return p, fmt.Errorf("%q is not a relative package pattern, like ./... or ./internal/...", s)
+	}
+	if s == "./..." || strings.HasSuffix(s, "/...") {
+		p.recursive = true
+		s = strings.TrimSuffix(s, "/...")
+	}
+	if strings.Contains(s, "...") {
+		return p, fmt.Errorf("%q can only have a wildcard at the end, like ./internal/...", s)
+	}
+
+	p.dir = path.Clean(s)
+	if p.dir == ".." || strings.HasPrefix(p.dir, "../") {
+		return p, fmt.Errorf("%q is outside of the path", s)
+	}
+
+	return p, nil
+}
+
+// String returns the pattern relative to the path, the way it's passed to 'go test' in a module at the path itself
+func (p packagePattern) String() string {
+	s := "./" + p.dir
+	if p.dir == "." {
+		s = "."
+	}
+	if p.recursive {
+		s = strings.TrimSuffix(s, "/.") + "/..."
+	}
+
+	return s
+}
+
+// matchesFile reports whether the slash-separated name, relative to the path, is in one of the packages matched
+func (p packagePattern) matchesFile(name string) bool {
+	dir := path.Dir(name)
+	if dir == p.dir || p.dir == "." && p.recursive {
+		return true
+	}
+
+	return p.recursive && strings.HasPrefix(dir, p.dir+"/")
+}
+
+// forModule returns the pattern to pass to 'go test' in the module at dir, relative to the path, or false when the
+// pattern doesn't match any package in that module
+func (p packagePattern) forModule(dir string) (string, bool) {
+	if dir == "." {
+		return p.String(), true
+	}
+
+	// The pattern is inside the module
+	if p.dir == dir || strings.HasPrefix(p.dir, dir+"/") {
+		rel := packagePattern{dir: strings.TrimPrefix(strings.TrimPrefix(p.dir, dir), "/"), recursive: p.recursive}
+		if rel.dir == "" {
+			rel.dir = "."
+		}
+		return rel.String(), true
+	}
+	// The module is inside of what the pattern matches
+	if p.recursive && (p.dir == "." || strings.HasPrefix(dir, p.dir+"/")) {
+		return "./...", true
+	}
+
+	return "", false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/tools/cover"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// profileLine matches a single block of a coverage profile, as documented by cover.ParseProfiles
+var profileLine = regexp.MustCompile(`^(.+):([0-9]+)\.([0-9]+),([0-9]+)\.([0-9]+) ([0-9]+) ([0-9]+)$`)
+
+// parseProfile parses the coverage profile in the named file, or from stdin when the name is '-'
+func parseProfile(name string) (profiles []*cover.Profile, err error) {
+	if name != "-" {
+		return cover.ParseProfiles(name)
+	}
+
+	// cover.ParseProfiles only reads files, so stdin is buffered to a temporary one first
+	f, err := os.CreateTemp(*tmpDir, "stdin-*.out")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file for coverage data: %w", err)
+	}
+	defer func() {
+		if rerr := os.Remove(f.Name()); rerr != nil && err == nil {
+			err = fmt.Errorf("removing temporary file for coverage data: %w", rerr)
+		}
+	}()
+
+	if err := copyProfile(f, os.Stdin); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return cover.ParseProfiles(f.Name())
+}
+
+// writeProfile writes the profiles to w in the format 'go test -coverprofile' writes them in, so they can be read by
+// 'go tool cover' and anything else that reads coverage profiles
+func writeProfile(w io.Writer, profiles []*cover.Profile) error {
+	bw := bufio.NewWriter(w)
+
+	mode := "set"
+	if len(profiles) > 0 {
+		mode = profiles[0].Mode
+	}
+	fmt.Fprintf(bw, "mode: %s\n", mode)
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", p.FileName, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// copyProfile copies the coverage profile in r to w. Anything but the first mode line and the profile blocks is
+// dropped, since 'go test -coverprofile=/dev/stdout' mixes the regular test output into the profile.
+func copyProfile(w io.Writer, r io.Reader) error {
+	mode := false
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+
+		switch {
+		case strings.HasPrefix(line, "mode: "):
+			if mode {
+				continue
+			}
+			mode = true
+		case !mode || !profileLine.MatchString(line):
+			continue
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
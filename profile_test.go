@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseProfileStdin(t *testing.T) {
+	setString(t, tmpDir, t.TempDir())
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = old })
+	go func() {
+		w.WriteString("mode: set\nexample.com/m/a.go:3.24,5.2 1 1\n")
+		w.Close()
+	}()
+
+	profiles, err := parseProfile("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[0].FileName != "example.com/m/a.go" {
+		t.Errorf("profiles = %+v, want the one for example.com/m/a.go", profiles)
+	}
+
+	// The copy of stdin is gone once it's parsed
+	left, err := os.ReadDir(*tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("%d files left in the temporary directory, like %s", len(left), left[0].Name())
+	}
+}
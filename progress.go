@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tehbilly/coverage-table/coveragetable"
+	"golang.org/x/term"
+	"os"
+	"time"
+)
+
+// spinnerFrames are shown one after the other while waiting
+var spinnerFrames = []rune{'|', '/', '-', '\\'}
+
+// startSpinner shows a spinner with message on stderr until the returned function is called, which clears the line
+// again. Nothing is shown when stderr isn't a terminal or when running with -quiet, as it would only end up as noise.
+func startSpinner(message string) (stop func()) {
+	if minLogLevel >= coveragetable.LevelError || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(os.Stderr, "\r%c %s", spinnerFrames[i%len(spinnerFrames)], message)
+			select {
+			case <-done:
+				// Clear the line, so whatever is written next starts on a clean one
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
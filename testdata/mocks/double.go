@@ -0,0 +1,5 @@
+package mocks
+
+func Double(n int) int {
+	return n * 2
+}
@@ -0,0 +1,9 @@
+package mocks
+
+type Store struct {
+	Values map[string]string
+}
+
+func (s *Store) Get(key string) string {
+	return s.Values[key]
+}
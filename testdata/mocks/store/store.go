@@ -0,0 +1,9 @@
+package store
+
+type Store interface {
+	Get(key string) string
+}
+
+func Key(prefix, name string) string {
+	return prefix + "/" + name
+}
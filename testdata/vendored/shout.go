@@ -0,0 +1,7 @@
+package vendored
+
+import "github.com/acme/strs"
+
+func Shout(s string) string {
+	return strs.Upper(s) + "!"
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/tehbilly/coverage-table/coveragetable"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tuiHelp is shown at the bottom of the screen, for the list and for the functions of a file
+const (
+	tuiHelp       = "↑/↓ move  enter functions  s sort  r reverse  / filter  q quit"
+	tuiListHelp   = "↑/↓ move  s sort  r reverse  / filter  q quit"
+	tuiDetailHelp = "↑/↓ move  esc back  q quit"
+)
+
+// tuiNouns name the rows in the title, by the Name of the options
+var tuiNouns = map[string]string{
+	"File":      "files",
+	"Package":   "packages",
+	"Function":  "functions",
+	"Directory": "directories",
+}
+
+// browser is the state of the interactive report shown by -tui
+type browser struct {
+	root string
+	r    coveragetable.Table
+	opts coveragetable.Options
+	// files is set when every row is a file, rather than a package or a function, so it has functions to show
+	files bool
+
+	// rows are the rows of r matching the filter, in the chosen order
+	rows       []coveragetable.Row
+	byCoverage bool
+	reversed   bool
+	filter     string
+	filtering  bool
+	cursor     int
+	offset     int
+
+	// funcs holds the functions of every file once they're asked for, detail the file they're shown for
+	funcs  map[string][]coveragetable.Row
+	detail string
+	// list keeps the cursor of the list while the functions of a file are shown
+	list struct{ cursor, offset int }
+}
+
+// tui lets the report be browsed with the keyboard until q is pressed. The rows of r are named relative to root, or
+// absolute. Only when files is set are they files, whose functions can be shown.
+func tui(root string, r coveragetable.Table, files bool, opts coveragetable.Options) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in, state)
+
+	// Switch to the alternate screen and hide the cursor, so the terminal is left as it was afterwards
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	b := &browser{root: root, r: r, opts: opts, files: files}
+	b.update()
+
+	// Reading blocks, so it happens on its own, leaving the loop below free to redraw when the terminal is resized
+	keys := make(chan byte, 64)
+	readErr := make(chan error, 1)
+	go func() {
+		stdin := bufio.NewReader(os.Stdin)
+		for {
+			c, err := stdin.ReadByte()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			keys <- c
+		}
+	}()
+	resized := make(chan struct{}, 1)
+	defer notifyResize(out, resized)()
+
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			return err
+		}
+		b.draw(os.Stdout, width, height)
+
+		select {
+		case <-resized:
+		case err := <-readErr:
+			return err
+		case c := <-keys:
+			if quit, err := b.handle(readKey(c, keys), height); quit || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// escapeTimeout is how long to wait for the rest of an escape sequence. Terminals send the bytes of a key press right
+// after each other, but not always in a single read, so an escape with nothing following it in time was pressed alone.
+const escapeTimeout = 50 * time.Millisecond
+
+// readKey reads the rest of the key press starting with c from in, turning the escape sequences of the keys that matter
+// into their names
+func readKey(c byte, in <-chan byte) string {
+	next := func() (byte, bool) {
+		select {
+		case c := <-in:
+			return c, true
+		case <-time.After(escapeTimeout):
+			return 0, false
+		}
+	}
+
+	if c != 0x1b {
+		if c < 0x80 {
+			return string(c)
+		}
+		// The first byte of a multi-byte character says how many more there are
+		buf := []byte{c}
+		for !utf8.FullRune(buf) {
+			c, ok := next()
+			if !ok {
+				break
+			}
+			buf = append(buf, c)
+		}
+		ch, _ := utf8.DecodeRune(buf)
+		return string(ch)
+	}
+
+	c, ok := next()
+	if !ok {
+		return "esc"
+	}
+	seq := []byte{c}
+	switch c {
+	case '[':
+		// A control sequence ends at its final byte, anything from '@' to '~'
+		for len(seq) < 8 {
+			c, ok := next()
+			if !ok {
+				break
+			}
+			seq = append(seq, c)
+			if c >= '@' && c <= '~' {
+				break
+			}
+		}
+	case 'O':
+		if c, ok := next(); ok {
+			seq = append(seq, c)
+		}
+	}
+	switch string(seq) {
+	case "[A", "OA":
+		return "up"
+	case "[B", "OB":
+		return "down"
+	case "[5~":
+		return "pgup"
+	case "[6~":
+		return "pgdown"
+	case "[H", "[1~":
+		return "home"
+	case "[F", "[4~":
+		return "end"
+	}
+
+	return ""
+}
+
+// handle updates the browser for a key press, returning true when it's time to quit
+func (b *browser) handle(key string, height int) (bool, error) {
+	if b.filtering {
+		switch key {
+		case "\r", "esc":
+			b.filtering = false
+		case "\x7f", "\b":
+			if b.filter != "" {
+				runes := []rune(b.filter)
+				b.filter = string(runes[:len(runes)-1])
+			}
+		default:
+			if len([]rune(key)) == 1 && key >= " " {
+				b.filter += key
+			}
+		}
+		b.update()
+		return false, nil
+	}
+
+	page := height - 3
+	switch key {
+	case "q", "\x03":
+		return true, nil
+	case "up", "k":
+		b.cursor--
+	case "down", "j":
+		b.cursor++
+	case "pgup":
+		b.cursor -= page
+	case "pgdown":
+		b.cursor += page
+	case "home", "g":
+		b.cursor = 0
+	case "end", "G":
+		b.cursor = len(b.visible())
+	case "esc", "\x7f", "h":
+		if b.detail != "" {
+			b.detail = ""
+			b.cursor, b.offset = b.list.cursor, b.list.offset
+		}
+	case "\r", "l":
+		if b.files && b.detail == "" && len(b.rows) > 0 {
+			if err := b.loadFuncs(); err != nil {
+				return true, err
+			}
+			b.detail = b.rows[b.cursor].Name
+			b.list.cursor, b.list.offset = b.cursor, b.offset
+			b.cursor, b.offset = 0, 0
+		}
+	case "s":
+		if b.detail == "" {
+			b.byCoverage = !b.byCoverage
+			b.update()
+		}
+	case "r":
+		if b.detail == "" {
+			b.reversed = !b.reversed
+			b.update()
+		}
+	case "/":
+		if b.detail == "" {
+			b.filtering = true
+		}
+	}
+
+	return false, nil
+}
+
+// update applies the filter and order to the rows of the report
+func (b *browser) update() {
+	filter := strings.ToLower(b.filter)
+	b.rows = b.rows[:0]
+	for _, row := range b.r.Rows {
+		if strings.Contains(strings.ToLower(row.Name), filter) {
+			b.rows = append(b.rows, row)
+		}
+	}
+
+	if b.byCoverage {
+		coveragetable.SortRowsByCoverage(b.rows)
+	}
+	if b.reversed {
+		for i, j := 0, len(b.rows)-1; i < j; i, j = i+1, j-1 {
+			b.rows[i], b.rows[j] = b.rows[j], b.rows[i]
+		}
+	}
+
+	b.cursor, b.offset = 0, 0
+}
+
+// loadFuncs finds the functions of every file, the first time they're needed
+func (b *browser) loadFuncs() error {
+	if b.funcs != nil {
+		return nil
+	}
+
+	grouped, err := coveragetable.GroupByFunc(b.root, b.r)
+	if err != nil {
+		return err
+	}
+
+	b.funcs = make(map[string][]coveragetable.Row)
+	for _, row := range grouped.Rows {
+		// Function rows are named 'file:line: name', where an absolute file name can have a colon of its own on Windows
+		line := row.Name[:strings.Index(row.Name, ": ")]
+		file := line[:strings.LastIndex(line, ":")]
+		row.Name = strings.TrimPrefix(row.Name, file+":")
+		b.funcs[file] = append(b.funcs[file], row)
+	}
+
+	return nil
+}
+
+// visible returns the rows on the current screen, either files or the functions of a file
+func (b *browser) visible() []coveragetable.Row {
+	if b.detail != "" {
+		return b.funcs[b.detail]
+	}
+	return b.rows
+}
+
+// draw writes the whole screen to w
+func (b *browser) draw(w io.Writer, width, height int) {
+	rows := b.visible()
+
+	// Keep the cursor on a row, and that row on the screen
+	page := height - 3
+	if page < 1 {
+		page = 1
+	}
+	if b.cursor >= len(rows) {
+		b.cursor = len(rows) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+page {
+		b.offset = b.cursor - page + 1
+	}
+
+	var s strings.Builder
+	s.WriteString("\033[H\033[2J")
+
+	noun, ok := tuiNouns[b.opts.Name]
+	if !ok {
+		noun = "rows"
+	}
+	title := fmt.Sprintf("%d %s, total %s%%", len(b.rows), noun, b.opts.Percent(b.r.Total.Percent()))
+	if b.detail != "" {
+		title = b.detail
+	} else {
+		order := "name"
+		if b.byCoverage {
+			order = "coverage"
+		}
+		if b.reversed {
+			order += ", reversed"
+		}
+		title += fmt.Sprintf(", sorted by %s", order)
+		if b.filter != "" || b.filtering {
+			title += fmt.Sprintf(", filter: %s", b.filter)
+		}
+	}
+	s.WriteString("\033[1m" + fit(title, width) + "\033[0m\r\n")
+
+	// The percentage and a bar go on the right, which leaves the rest of the line for the name
+	const right = 20
+	for i := b.offset; i < len(rows) && i < b.offset+page; i++ {
+		row := rows[i]
+		cov := "-"
+		bar := strings.Repeat(" ", 10)
+		if !row.Empty {
+			cov = b.opts.Percent(row.Percent())
+			filled := int(row.Percent() / 10)
+			bar = strings.Repeat("█", filled) + strings.Repeat("░", 10-filled)
+		}
+		line := fmt.Sprintf("%-*s %8s %s", width-right, fit(row.Name, width-right), cov, bar)
+		if i == b.cursor {
+			line = "\033[7m" + line + "\033[0m"
+		}
+		s.WriteString(line + "\r\n")
+	}
+	if len(rows) == 0 {
+		s.WriteString("Nothing to show\r\n")
+	}
+
+	help := tuiHelp
+	if !b.files {
+		help = tuiListHelp
+	}
+	if b.detail != "" {
+		help = tuiDetailHelp
+	} else if b.filtering {
+		help = "type to filter  enter done"
+	}
+	fmt.Fprintf(&s, "\033[%d;1H\033[2m%s\033[0m", height, fit(help, width))
+
+	io.WriteString(w, s.String())
+}
+
+// fit cuts s down to width, keeping the end of it as that's where the file name is
+func fit(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return s
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
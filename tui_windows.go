@@ -0,0 +1,37 @@
+package main
+
+import (
+	"golang.org/x/term"
+	"time"
+)
+
+// notifyResize sends on resized whenever the terminal is resized, until the returned function is called. There's no
+// signal for it on Windows, so the size is checked a few times a second instead.
+func notifyResize(fd int, resized chan<- struct{}) func() {
+	done := make(chan struct{})
+	go func() {
+		width, height, _ := term.GetSize(fd)
+		tick := time.NewTicker(250 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				w, h, err := term.GetSize(fd)
+				if err != nil || w == width && h == height {
+					continue
+				}
+				width, height = w, h
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
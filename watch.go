@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchDebounce is how long to wait for things to settle after a change, so saving several files at once only
+// triggers a single run
+const watchDebounce = 300 * time.Millisecond
+
+// watch renders the report for root, and then again every time a go file under root changes, until interrupted
+func watch(root string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	// fsnotify doesn't watch recursively, so every directory needs to be added on its own
+	if err := watchDirs(w, root); err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	// Start with a run straight away instead of waiting for the first change
+	debounce := time.NewTimer(0)
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case err := <-w.Errors:
+			return err
+		case event := <-w.Events:
+			if event.Op&fsnotify.Create != 0 {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := watchDirs(w, event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			if !strings.HasSuffix(event.Name, ".go") || event.Op == fsnotify.Chmod {
+				continue
+			}
+			// Drain a run that's already due, it'll happen once things settle down anyway
+			if !debounce.Stop() {
+				select {
+				case <-debounce.C:
+				default:
+				}
+			}
+			debounce.Reset(watchDebounce)
+		case <-debounce.C:
+			rerun()
+		}
+	}
+}
+
+// watchDirs adds dir and every directory below it to w, skipping hidden directories just like findGoFiles does
+func watchDirs(w *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if p != dir && strings.HasPrefix(fi.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		return w.Add(p)
+	})
+}
+
+// rerun clears the screen and runs coverage-table again with the same arguments, minus -watch. Running a fresh
+// process keeps every run independent of the ones before it, and a failing gate doesn't stop the watching.
+func rerun() {
+	fmt.Print("\033[H\033[2J")
+
+	exe, err := os.Executable()
+	if err != nil {
+		errorf("Unable to find coverage-table executable: %s", err)
+		return
+	}
+
+	args := make([]string, 0, len(os.Args)-1)
+	for _, arg := range os.Args[1:] {
+		name := strings.TrimLeft(arg, "-")
+		if strings.HasPrefix(arg, "-") && (name == "watch" || strings.HasPrefix(name, "watch=")) {
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// The exit code is already explained by whatever was written to stderr
+	_ = cmd.Run()
+
+	fmt.Printf("\nLast run at %s, watching for changes, press Ctrl-C to stop\n", time.Now().Format("15:04:05"))
+}